@@ -0,0 +1,111 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	goyaml "gopkg.in/yaml.v2"
+)
+
+// Base represents an operating system and channel that a charm
+// can be deployed on.
+type Base struct {
+	// Name holds the name of the operating system, for
+	// instance "ubuntu".
+	Name string `yaml:"name"`
+
+	// Channel holds the operating system channel, for
+	// instance "16.04".
+	Channel string `yaml:"channel"`
+
+	// Architectures optionally holds the architectures
+	// supported on this base.
+	Architectures []string `yaml:"architectures,omitempty"`
+}
+
+// String returns the base in the form "name@channel".
+func (b Base) String() string {
+	return b.Name + "@" + b.Channel
+}
+
+// CharmManifest holds the contents of the manifest.yaml file
+// shipped with charms using the newer charm format.
+type CharmManifest struct {
+	// Bases holds the bases supported by the charm.
+	Bases []Base `yaml:"bases"`
+}
+
+// ReadCharmManifest reads a charm manifest in YAML format.
+func ReadCharmManifest(r io.Reader) (*CharmManifest, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var manifest CharmManifest
+	if err := goyaml.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %v", err)
+	}
+	if len(manifest.Bases) == 0 {
+		return nil, fmt.Errorf("invalid manifest: no bases specified")
+	}
+	for i, base := range manifest.Bases {
+		if base.Name == "" {
+			return nil, fmt.Errorf("invalid manifest: base %d has no name", i)
+		}
+		if base.Channel == "" {
+			return nil, fmt.Errorf("invalid manifest: base %q has no channel", base.Name)
+		}
+	}
+	return &manifest, nil
+}
+
+// ubuntuSeriesChannels maps the known Ubuntu series to
+// their corresponding channels.
+var ubuntuSeriesChannels = map[string]string{
+	"precise": "12.04",
+	"quantal": "12.10",
+	"raring":  "13.04",
+	"saucy":   "13.10",
+	"trusty":  "14.04",
+	"utopic":  "14.10",
+	"vivid":   "15.04",
+	"wily":    "15.10",
+	"xenial":  "16.04",
+	"yakkety": "16.10",
+}
+
+// BaseForSeries returns the base corresponding to the given
+// Ubuntu series.
+func BaseForSeries(series string) (Base, error) {
+	channel, ok := ubuntuSeriesChannels[series]
+	if !ok {
+		return Base{}, fmt.Errorf("unknown series %q", series)
+	}
+	return Base{Name: "ubuntu", Channel: channel}, nil
+}
+
+// SupportedBases returns the bases supported by the given charm.
+// If the charm has a manifest declaring its bases, those are
+// returned. Otherwise the bases are derived from the series
+// declared in the charm metadata, ignoring any series that
+// do not correspond to a known base.
+func SupportedBases(ch Charm) []Base {
+	if m, ok := ch.(interface {
+		CharmManifest() *CharmManifest
+	}); ok {
+		if manifest := m.CharmManifest(); manifest != nil {
+			return manifest.Bases
+		}
+	}
+	var bases []Base
+	for _, series := range ch.Meta().Series {
+		if base, err := BaseForSeries(series); err == nil {
+			bases = append(bases, base)
+		}
+	}
+	return bases
+}
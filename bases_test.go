@@ -0,0 +1,131 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"gopkg.in/juju/charm.v6-unstable"
+)
+
+type BasesSuite struct{}
+
+var _ = gc.Suite(&BasesSuite{})
+
+var expectedBases = []charm.Base{{
+	Name:          "ubuntu",
+	Channel:       "16.04",
+	Architectures: []string{"amd64", "arm64"},
+}, {
+	Name:    "ubuntu",
+	Channel: "18.04",
+}}
+
+var readCharmManifestErrorTests = []struct {
+	about string
+	data  string
+	err   string
+}{{
+	about: "no bases",
+	data:  "bases: []",
+	err:   "invalid manifest: no bases specified",
+}, {
+	about: "missing name",
+	data:  `bases: [{channel: "16.04"}]`,
+	err:   "invalid manifest: base 0 has no name",
+}, {
+	about: "missing channel",
+	data:  "bases: [{name: ubuntu}]",
+	err:   `invalid manifest: base "ubuntu" has no channel`,
+}, {
+	about: "invalid YAML",
+	data:  "bases: {",
+	err:   "invalid manifest: yaml: .*",
+}}
+
+func (s *BasesSuite) TestReadCharmManifestErrors(c *gc.C) {
+	for i, test := range readCharmManifestErrorTests {
+		c.Logf("test %d: %s", i, test.about)
+		manifest, err := charm.ReadCharmManifest(strings.NewReader(test.data))
+		c.Assert(err, gc.ErrorMatches, test.err)
+		c.Assert(manifest, gc.IsNil)
+	}
+}
+
+func (s *BasesSuite) TestCharmDirManifest(c *gc.C) {
+	dir := readCharmDir(c, "bases")
+	c.Assert(dir.CharmManifest(), jc.DeepEquals, &charm.CharmManifest{
+		Bases: expectedBases,
+	})
+	c.Assert(charm.SupportedBases(dir), jc.DeepEquals, expectedBases)
+}
+
+func (s *BasesSuite) TestCharmArchiveManifest(c *gc.C) {
+	archive := archiveDir(c, charmDirPath(c, "bases"))
+	c.Assert(archive.CharmManifest(), jc.DeepEquals, &charm.CharmManifest{
+		Bases: expectedBases,
+	})
+	c.Assert(charm.SupportedBases(archive), jc.DeepEquals, expectedBases)
+}
+
+func (s *BasesSuite) TestReadCharmWithInvalidManifest(c *gc.C) {
+	path := cloneDir(c, charmDirPath(c, "bases"))
+	dir, err := charm.ReadCharmDir(path)
+	c.Assert(err, gc.IsNil)
+	err = ioutil.WriteFile(filepath.Join(path, "manifest.yaml"), []byte("bases: [{name: ubuntu}]"), 0644)
+	c.Assert(err, gc.IsNil)
+
+	_, err = charm.ReadCharmDir(path)
+	c.Assert(err, gc.ErrorMatches, `cannot read manifest.yaml: invalid manifest: base "ubuntu" has no channel`)
+
+	// The directory was read before the manifest was broken,
+	// so it can still be archived.
+	var buf bytes.Buffer
+	err = dir.ArchiveTo(&buf)
+	c.Assert(err, gc.IsNil)
+	_, err = charm.ReadCharmArchiveBytes(buf.Bytes())
+	c.Assert(err, gc.ErrorMatches, `cannot read manifest.yaml: invalid manifest: base "ubuntu" has no channel`)
+}
+
+func (s *BasesSuite) TestSupportedBasesFromSeries(c *gc.C) {
+	path := cloneDir(c, charmDirPath(c, "dummy"))
+	err := ioutil.WriteFile(filepath.Join(path, "metadata.yaml"), []byte(`
+name: dummy
+summary: dummy
+description: dummy
+series: [xenial, trusty, unknown]
+`), 0644)
+	c.Assert(err, gc.IsNil)
+
+	dir, err := charm.ReadCharmDir(path)
+	c.Assert(err, gc.IsNil)
+	c.Assert(dir.CharmManifest(), gc.IsNil)
+	c.Assert(charm.SupportedBases(dir), jc.DeepEquals, []charm.Base{
+		{Name: "ubuntu", Channel: "16.04"},
+		{Name: "ubuntu", Channel: "14.04"},
+	})
+
+	archive := archiveDir(c, path)
+	c.Assert(archive.CharmManifest(), gc.IsNil)
+	c.Assert(charm.SupportedBases(archive), jc.DeepEquals, []charm.Base{
+		{Name: "ubuntu", Channel: "16.04"},
+		{Name: "ubuntu", Channel: "14.04"},
+	})
+}
+
+func (s *BasesSuite) TestBaseForSeries(c *gc.C) {
+	base, err := charm.BaseForSeries("xenial")
+	c.Assert(err, gc.IsNil)
+	c.Assert(base, jc.DeepEquals, charm.Base{Name: "ubuntu", Channel: "16.04"})
+	c.Assert(base.String(), gc.Equals, "ubuntu@16.04")
+
+	_, err = charm.BaseForSeries("unknown")
+	c.Assert(err, gc.ErrorMatches, `unknown series "unknown"`)
+}
@@ -3,7 +3,11 @@
 
 package charm
 
-import "os"
+import (
+	"os"
+	"sort"
+	"strings"
+)
 
 // The Bundle interface is implemented by any type that
 // may be handled as a bundle. It encapsulates all
@@ -11,10 +15,33 @@ import "os"
 type Bundle interface {
 	// Data returns the contents of the bundle's bundle.yaml file.
 	Data() *BundleData
-	// Data returns the contents of the bundle's README.md file.
+	// Data returns the contents of the bundle's README file.
 	ReadMe() string
 }
 
+// readMeNames holds the lower-cased names of the files that
+// are accepted as bundle README files, in order of preference.
+var readMeNames = []string{"readme.md", "readme", "readme.txt"}
+
+// findReadMe returns the name of the README file found in the given
+// names of the files at the root of a bundle, or the empty string if
+// there is none. File names are matched case-insensitively, and
+// README.md is preferred over any other variant.
+func findReadMe(names []string) string {
+	names = append([]string(nil), names...)
+	// Sort the names so that upper case variants,
+	// such as README.md, come first.
+	sort.Strings(names)
+	for _, readMeName := range readMeNames {
+		for _, name := range names {
+			if strings.ToLower(name) == readMeName {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
 // ReadBundle reads a Bundle from path, which can point to either a
 // bundle archive or a bundle directory.
 func ReadBundle(path string) (Bundle, error) {
@@ -64,11 +64,21 @@ func readBundleArchive(zopen zipOpener) (*BundleArchive, error) {
 	if err != nil {
 		return nil, err
 	}
-	reader, err = zipOpenFile(zipr, "README.md")
+	var names []string
+	for _, f := range zipr.File {
+		names = append(names, f.Name)
+	}
+	readMeName := findReadMe(names)
+	if readMeName == "" {
+		// Report the error for the canonical file name.
+		readMeName = "README.md"
+	}
+	reader, err = zipOpenFile(zipr, readMeName)
 	if err != nil {
 		return nil, err
 	}
 	readMe, err := ioutil.ReadAll(reader)
+	reader.Close()
 	if err != nil {
 		return nil, err
 	}
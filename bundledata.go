@@ -15,6 +15,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/juju/version"
 	"gopkg.in/juju/names.v2"
 	"gopkg.in/mgo.v2/bson"
 	"gopkg.in/yaml.v2"
@@ -117,6 +118,14 @@ type BundleData struct {
 	// Short paragraph explaining what the bundle is useful for.
 	Description string `bson:",omitempty" json:",omitempty" yaml:",omitempty"`
 
+	// Docs holds the URL of the bundle documentation.
+	Docs string `bson:",omitempty" json:",omitempty" yaml:",omitempty"`
+
+	// Annotations holds any annotations to apply to the
+	// bundle as a whole, such as the environment it
+	// is intended for.
+	Annotations map[string]string `bson:",omitempty" json:",omitempty" yaml:",omitempty"`
+
 	// unmarshaledWithServices holds whether the original marshaled data held a
 	// legacy "services" field rather than the "applications" field.
 	unmarshaledWithServices bool
@@ -276,8 +285,17 @@ type bundleDataVerifier struct {
 	// as referred to by placement directives.
 	machineRefCounts map[string]int
 
+	// machineUnitRefCounts holds the number of placement
+	// directives placing units directly on each machine,
+	// rather than in a container.
+	machineUnitRefCounts map[string]int
+
 	charms map[string]Charm
 
+	// placements caches the normalized placement directives
+	// of each application, as returned by appPlacements.
+	placements map[string][]string
+
 	errors            []error
 	verifyConstraints func(c string) error
 	verifyStorage     func(s string) error
@@ -299,7 +317,10 @@ func (verifier *bundleDataVerifier) err() error {
 }
 
 // RequiredCharms returns a sorted slice of all the charm URLs
-// required by the bundle.
+// required by the bundle. Local charm paths are returned as written
+// in the bundle, as they are only meaningful relative to the bundle
+// directory, and so that the result can be used as the keys of the
+// charms map given to VerifyLocalWithCharms and RequiredTerms.
 func (bd *BundleData) RequiredCharms() []string {
 	req := make([]string, 0, len(bd.Applications))
 	for _, svc := range bd.Applications {
@@ -309,13 +330,372 @@ func (bd *BundleData) RequiredCharms() []string {
 	return req
 }
 
+// RequiredTerms returns a sorted slice of all the terms that must be
+// agreed to in order to deploy the bundle, as declared by the charms
+// used by its applications, without duplicates. The charms map should
+// hold an entry for each charm URL returned by bd.RequiredCharms;
+// charms that are not found in the map are ignored.
+func (bd *BundleData) RequiredTerms(charms map[string]Charm) []string {
+	terms := make(map[string]bool)
+	for _, svc := range bd.Applications {
+		ch := charms[svc.Charm]
+		if ch == nil {
+			continue
+		}
+		for _, term := range ch.Meta().Terms {
+			terms[term] = true
+		}
+	}
+	req := make([]string, 0, len(terms))
+	for term := range terms {
+		req = append(req, term)
+	}
+	sort.Strings(req)
+	return req
+}
+
+// VerifyJujuVersion checks that all the charms used by the bundle can be
+// deployed by the given target Juju version, reporting the charms that
+// declare a minimum Juju version greater than the target. The charms map
+// should hold an entry for each charm URL returned by bd.RequiredCharms;
+// charms that are not found in the map, and charms that do not declare
+// a minimum Juju version, are ignored.
+//
+// If the verification fails, VerifyJujuVersion returns a
+// *VerificationError describing all the problems found.
+func (bd *BundleData) VerifyJujuVersion(charms map[string]Charm, target version.Number) error {
+	verifier := &bundleDataVerifier{bd: bd}
+	for name, svc := range bd.Applications {
+		ch := charms[svc.Charm]
+		if ch == nil {
+			continue
+		}
+		minver := ch.Meta().MinJujuVersion
+		if minver != version.Zero && minver.Compare(target) > 0 {
+			verifier.addErrorf("charm %q used by application %q requires Juju %s or later, but the target version is %s", svc.Charm, name, minver, target)
+		}
+	}
+	return verifier.err()
+}
+
+// NewMachineCount returns the number of machines that deploying the
+// bundle would create. This includes the machines declared in the
+// machines section and one machine for each unit placed on a "new"
+// machine, either directly or inside a container. Units without
+// enough placement directives follow the replication rule described
+// in ApplicationSpec.To, so unplaced units each get a new machine.
+// Placement directives that cannot be parsed are ignored.
+func (bd *BundleData) NewMachineCount() int {
+	count := len(bd.Machines)
+	for _, app := range bd.Applications {
+		if app == nil {
+			continue
+		}
+		for _, p := range normalizedPlacements(app.To, app.NumUnits) {
+			if up, err := ParsePlacement(p); err == nil && up.Machine == "new" {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// VerifyMachineBudget checks that deploying the bundle would not
+// create more than budget machines, as computed by NewMachineCount.
+//
+// If the verification fails, VerifyMachineBudget returns a
+// *VerificationError describing the problem.
+func (bd *BundleData) VerifyMachineBudget(budget int) error {
+	verifier := &bundleDataVerifier{bd: bd}
+	if count := bd.NewMachineCount(); count > budget {
+		verifier.addErrorf("bundle requires %d machine(s) (%d declared, %d implied by placement directives), exceeding the budget of %d", count, len(bd.Machines), count-len(bd.Machines), budget)
+	}
+	return verifier.err()
+}
+
+// DefaultReservedAnnotationPrefixes holds the annotation key prefixes
+// reserved by default by VerifyAnnotationKeys: "gui-" keys are used
+// by the GUI to position applications and machines, and "juju-" is
+// reserved for Juju itself, as with charm names.
+var DefaultReservedAnnotationPrefixes = []string{"gui-", "juju-"}
+
+// VerifyAnnotationKeys checks that no annotation in the bundle, or in
+// its applications and machines, uses a key in a reserved namespace.
+// Each entry of reserved holds a reserved key prefix. If reserved is
+// nil, DefaultReservedAnnotationPrefixes is used. Callers that want
+// to allow reserved keys, for instance because the bundle was exported
+// by the GUI itself, can opt out by passing an empty non-nil slice.
+//
+// If the verification fails, VerifyAnnotationKeys returns a
+// *VerificationError describing all the problems found.
+func (bd *BundleData) VerifyAnnotationKeys(reserved []string) error {
+	if reserved == nil {
+		reserved = DefaultReservedAnnotationPrefixes
+	}
+	verifier := &bundleDataVerifier{bd: bd}
+	check := func(annotations map[string]string, owner string) {
+		for key := range annotations {
+			for _, prefix := range reserved {
+				if strings.HasPrefix(key, prefix) {
+					verifier.addErrorf("%s declares annotation %q in reserved namespace %q", owner, key, prefix)
+					break
+				}
+			}
+		}
+	}
+	check(bd.Annotations, "bundle")
+	for name, app := range bd.Applications {
+		if app != nil {
+			check(app.Annotations, fmt.Sprintf("application %q", name))
+		}
+	}
+	for id, machine := range bd.Machines {
+		if machine != nil {
+			check(machine.Annotations, fmt.Sprintf("machine %q", id))
+		}
+	}
+	return verifier.err()
+}
+
+// VerifyRelationLimits checks that no application endpoint takes part
+// in more relations than the limit declared for it in the charm
+// metadata. The charms map should hold an entry for each charm URL
+// returned by bd.RequiredCharms. Note that the charm metadata gives
+// required relations a limit of 1 unless otherwise specified, and a
+// limit of 0 means no limit.
+//
+// Relations that cannot be parsed or resolved against the charms,
+// which are reported by VerifyWithCharms, are ignored.
+//
+// If the verification fails, VerifyRelationLimits returns a
+// *VerificationError describing all the problems found.
+func (bd *BundleData) VerifyRelationLimits(charms map[string]Charm) error {
+	verifier := &bundleDataVerifier{
+		bd:     bd,
+		charms: charms,
+	}
+	seen := make(map[[2]endpoint]bool)
+	counts := make(map[endpoint]int)
+	for _, relPair := range bd.Relations {
+		if len(relPair) != 2 {
+			continue
+		}
+		ep0, err0 := parseEndpoint(relPair[0])
+		ep1, err1 := parseEndpoint(relPair[1])
+		if err0 != nil || err1 != nil {
+			continue
+		}
+		ep0, ep1, err := inferEndpoints(ep0, ep1, verifier.getCharmMetaForApplication)
+		if err != nil {
+			continue
+		}
+		if ep1.less(ep0) {
+			ep0, ep1 = ep1, ep0
+		}
+		if seen[[2]endpoint{ep0, ep1}] {
+			continue
+		}
+		seen[[2]endpoint{ep0, ep1}] = true
+		counts[ep0]++
+		counts[ep1]++
+	}
+	for ep, count := range counts {
+		meta, err := verifier.getCharmMetaForApplication(ep.application)
+		if err != nil {
+			continue
+		}
+		rel, ok := meta.CombinedRelations()[ep.relation]
+		if ok && rel.Limit > 0 && count > rel.Limit {
+			verifier.addErrorf("relation %q of application %q has a limit of %d but is used by %d relations", ep.relation, ep.application, rel.Limit, count)
+		}
+	}
+	return verifier.err()
+}
+
+// VerifyCharmSchema checks that the charms used by the bundle
+// applications all come from the given kind of repository, reporting
+// the applications whose charm URL uses a different schema. The
+// schema is either "cs" or "local". Local charm paths are considered
+// to use the "local" schema, and charm URLs without a schema are
+// considered to use the "cs" schema, as with ParseURL. Invalid charm
+// URLs, which are reported by Verify, are ignored.
+//
+// Bundles mixing charms from different sources are valid, so this
+// verification is not performed by Verify.
+//
+// If the verification fails, VerifyCharmSchema returns a
+// *VerificationError describing all the problems found.
+func (bd *BundleData) VerifyCharmSchema(schema string) error {
+	verifier := &bundleDataVerifier{bd: bd}
+	for name, app := range bd.Applications {
+		charmSchema := "local"
+		if !isLocalCharmPath(app.Charm) {
+			curl, err := ParseURL(app.Charm)
+			if err != nil {
+				continue
+			}
+			charmSchema = curl.Schema
+		}
+		if charmSchema != schema {
+			verifier.addErrorf("charm %q used by application %q has schema %q, expected %q", app.Charm, name, charmSchema, schema)
+		}
+	}
+	return verifier.err()
+}
+
+// VerifyArchitectures checks that the bundle does not place units of
+// charms with incompatible architectures on the same machine. The
+// archs map holds the architectures supported by each charm, keyed by
+// the charm URL as found in the bundle's applications. Units of charms
+// with no entry in archs, or with an empty list of architectures,
+// are compatible with any machine.
+//
+// Units placed inside containers are considered to be on the
+// host machine, as containers share the host architecture.
+//
+// The bundle is expected to have been verified already: placement
+// directives that cannot be parsed or that refer to undefined
+// applications or units are ignored.
+//
+// If the verification fails, VerifyArchitectures returns a
+// *VerificationError describing all the problems found.
+func (bd *BundleData) VerifyArchitectures(archs map[string][]string) error {
+	verifier := &bundleDataVerifier{bd: bd}
+	// hostApps holds the names of the applications with units on
+	// each host machine, considering only the charms with known
+	// architectures.
+	hostApps := make(map[string][]string)
+	for name, app := range bd.Applications {
+		if app == nil || len(archs[app.Charm]) == 0 {
+			continue
+		}
+		for unit := 0; unit < app.NumUnits; unit++ {
+			host, ok := verifier.unitHost(name, unit, make(map[string]bool))
+			if !ok {
+				continue
+			}
+			if apps := hostApps[host]; len(apps) == 0 || apps[len(apps)-1] != name {
+				hostApps[host] = append(apps, name)
+			}
+		}
+	}
+	for host, apps := range hostApps {
+		if len(apps) < 2 {
+			continue
+		}
+		sort.Strings(apps)
+		common := make(map[string]bool)
+		for _, arch := range archs[bd.Applications[apps[0]].Charm] {
+			common[arch] = true
+		}
+		for _, name := range apps[1:] {
+			supported := make(map[string]bool)
+			for _, arch := range archs[bd.Applications[name].Charm] {
+				supported[arch] = true
+			}
+			for arch := range common {
+				if !supported[arch] {
+					delete(common, arch)
+				}
+			}
+		}
+		if len(common) == 0 {
+			verifier.addErrorf("applications %s placed on %s have no architecture in common", quoteNames(apps), host)
+		}
+	}
+	return verifier.err()
+}
+
+// EffectiveOptions returns the complete configuration that an
+// application deployed from the given spec would have: the default
+// value of every option in the charm config, overridden by the
+// options specified in the bundle. It returns an error if the
+// bundle options include unknown options or values of the wrong type.
+func EffectiveOptions(config *Config, spec *ApplicationSpec) (Settings, error) {
+	options, err := config.ValidateSettings(spec.Options)
+	if err != nil {
+		return nil, err
+	}
+	settings := config.DefaultSettings()
+	for name, value := range options {
+		settings[name] = value
+	}
+	return settings, nil
+}
+
+// isLocalCharmPath reports whether the given charm, as specified
+// in a bundle application, refers to a local charm directory
+// rather than to a charm URL.
+func isLocalCharmPath(charm string) bool {
+	return strings.HasPrefix(charm, ".") || filepath.IsAbs(charm)
+}
+
+// isCharmDir reports whether the given directory
+// holds a charm, i.e. it contains a metadata.yaml file.
+func isCharmDir(path string) bool {
+	info, err := os.Stat(filepath.Join(path, "metadata.yaml"))
+	return err == nil && !info.IsDir()
+}
+
+// RewriteURLs applies the given rewrite function to the URL of the
+// charm used by each application in the bundle, and updates the
+// applications with the returned URLs. Applications using local
+// charm directories, and applications whose URL is not changed by
+// the rewrite function, are left unchanged, so the charm keeps the
+// form it was specified with in the bundle.
+//
+// If the rewrite function returns an error, or if any charm URL
+// cannot be parsed or rewritten to a valid URL, RewriteURLs returns
+// an error and the bundle is not modified.
+func (bd *BundleData) RewriteURLs(rewrite func(*URL) (*URL, error)) error {
+	names := make([]string, 0, len(bd.Applications))
+	for name := range bd.Applications {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	charms := make(map[string]string)
+	for _, name := range names {
+		app := bd.Applications[name]
+		if isLocalCharmPath(app.Charm) {
+			continue
+		}
+		curl, err := ParseURL(app.Charm)
+		if err != nil {
+			return fmt.Errorf("cannot rewrite charm URL in application %q: %v", name, err)
+		}
+		newURL, err := rewrite(curl)
+		if err != nil {
+			return fmt.Errorf("cannot rewrite charm URL in application %q: %v", name, err)
+		}
+		if newURL == nil {
+			return fmt.Errorf("cannot rewrite charm URL in application %q: no URL returned", name)
+		}
+		// Check that the new URL round-trips, so that
+		// the resulting bundle can be read back.
+		if _, err := ParseURL(newURL.String()); err != nil {
+			return fmt.Errorf("cannot rewrite charm URL in application %q: %v", name, err)
+		}
+		if newURL.String() != curl.String() {
+			charms[name] = newURL.String()
+		}
+	}
+	for name, charm := range charms {
+		bd.Applications[name].Charm = charm
+	}
+	return nil
+}
+
 // VerifyLocal verifies that a local bundle file is consistent.
 // A local bundle file may contain references to charms which are
 // referred to by a directory, either relative or absolute.
 //
 // bundleDir is used to construct the full path for charms specified
 // using a relative directory path. The charm path is therefore expected
-// to be relative to the bundle.yaml file.
+// to be relative to the bundle.yaml file. A charm directory must
+// contain a metadata.yaml file.
+//
+// Local charms are reported by RequiredCharms using the charm path
+// as specified in the bundle.
 func (bd *BundleData) VerifyLocal(
 	bundleDir string,
 	verifyConstraints func(c string) error,
@@ -324,6 +704,20 @@ func (bd *BundleData) VerifyLocal(
 	return bd.verifyBundle(bundleDir, verifyConstraints, verifyStorage, nil)
 }
 
+// VerifyLocalWithCharms is like VerifyLocal, but it also verifies the
+// bundle against the given charms, as described in VerifyWithCharms.
+// Local charms are looked up in the charms map using the charm path
+// as specified in the bundle, so that relations to local charms can
+// be checked against their metadata.
+func (bd *BundleData) VerifyLocalWithCharms(
+	bundleDir string,
+	verifyConstraints func(c string) error,
+	verifyStorage func(s string) error,
+	charms map[string]Charm,
+) error {
+	return bd.verifyBundle(bundleDir, verifyConstraints, verifyStorage, charms)
+}
+
 // Verify is a convenience method that calls VerifyWithCharms
 // with a nil charms map.
 func (bd *BundleData) Verify(
@@ -346,6 +740,12 @@ func (bd *BundleData) Verify(
 // - All applications referred to by relations are specified in the bundle.
 // - All basic constraints are valid.
 // - All storage constraints are valid.
+// - No unit is placed in a new container on a unit which is itself in a container.
+// - No application placed directly on machines has conflicting constraints.
+//
+// If charms is not nil, it also verifies that subordinate applications
+// have no units or placement directives, and that each of them is
+// related to at least one principal application.
 //
 // If charms is not nil, it should hold a map with an entry for each
 // charm url returned by bd.RequiredCharms. The verification will then
@@ -379,12 +779,13 @@ func (bd *BundleData) verifyBundle(
 		}
 	}
 	verifier := &bundleDataVerifier{
-		bundleDir:         bundleDir,
-		verifyConstraints: verifyConstraints,
-		verifyStorage:     verifyStorage,
-		bd:                bd,
-		machineRefCounts:  make(map[string]int),
-		charms:            charms,
+		bundleDir:            bundleDir,
+		verifyConstraints:    verifyConstraints,
+		verifyStorage:        verifyStorage,
+		bd:                   bd,
+		machineRefCounts:     make(map[string]int),
+		machineUnitRefCounts: make(map[string]int),
+		charms:               charms,
 	}
 	for id := range bd.Machines {
 		verifier.machineRefCounts[id] = 0
@@ -394,6 +795,8 @@ func (bd *BundleData) verifyBundle(
 	}
 	verifier.verifyMachines()
 	verifier.verifyApplications()
+	verifier.verifyNestedContainers()
+	verifier.verifyConstraintConflicts()
 	verifier.verifyRelations()
 	verifier.verifyOptions()
 	verifier.verifyEndpointBindings()
@@ -401,6 +804,8 @@ func (bd *BundleData) verifyBundle(
 	for id, count := range verifier.machineRefCounts {
 		if count == 0 {
 			verifier.addErrorf("machine %q is not referred to by a placement directive", id)
+		} else if verifier.machineUnitRefCounts[id] == 0 {
+			verifier.warnContainerOnlyMachine(id)
 		}
 	}
 	return verifier.err()
@@ -430,6 +835,28 @@ func (verifier *bundleDataVerifier) verifyMachines() {
 	}
 }
 
+// instanceConstraints holds the constraints that only apply
+// when provisioning an instance, rather than a container.
+var instanceConstraints = []string{"instance-type", "root-disk"}
+
+// warnContainerOnlyMachine logs a warning if the given machine, which
+// is only referred to by container placements, has constraints that
+// only apply to instances. Such constraints are still used when
+// provisioning the machine, but users often expect them to affect the
+// containers instead.
+func (verifier *bundleDataVerifier) warnContainerOnlyMachine(id string) {
+	m := verifier.bd.Machines[id]
+	if m == nil {
+		return
+	}
+	values := parseConstraintValues(m.Constraints)
+	for _, key := range instanceConstraints {
+		if value, ok := values[key]; ok {
+			logger.Warningf("machine %q only hosts containers but has constraint \"%s=%s\", which does not apply to containers", id, key, value)
+		}
+	}
+}
+
 func (verifier *bundleDataVerifier) verifyApplications() {
 	if len(verifier.bd.Applications) == 0 {
 		verifier.addErrorf("at least one application must be specified")
@@ -442,17 +869,19 @@ func (verifier *bundleDataVerifier) verifyApplications() {
 		// Charm may be a local directory or a charm URL.
 		var curl *URL
 		var err error
-		if strings.HasPrefix(svc.Charm, ".") || filepath.IsAbs(svc.Charm) {
+		if isLocalCharmPath(svc.Charm) {
 			charmPath := svc.Charm
 			if !filepath.IsAbs(charmPath) {
 				charmPath = filepath.Join(verifier.bundleDir, charmPath)
 			}
-			if _, err := os.Stat(charmPath); err != nil {
+			if info, err := os.Stat(charmPath); err != nil {
 				if os.IsNotExist(err) {
 					verifier.addErrorf("charm path in application %q does not exist: %v", name, charmPath)
 				} else {
 					verifier.addErrorf("invalid charm path in application %q: %v", name, err)
 				}
+			} else if info.IsDir() && !isCharmDir(charmPath) {
+				verifier.addErrorf("charm path in application %q does not point to a charm directory: %v", name, charmPath)
 			}
 		} else if curl, err = ParseURL(svc.Charm); err != nil {
 			verifier.addErrorf("invalid charm URL in application %q: %v", name, err)
@@ -501,13 +930,16 @@ func (verifier *bundleDataVerifier) verifyApplications() {
 		if svc.NumUnits < 0 {
 			verifier.addErrorf("negative number of units specified on application %q", name)
 		} else if len(svc.To) > svc.NumUnits {
-			verifier.addErrorf("too many units specified in unit placement for application %q", name)
+			verifier.addErrorf("too many units specified in unit placement for application %q: %d placement directive(s) for %d unit(s)", name, len(svc.To), svc.NumUnits)
+		} else if len(svc.To) > 0 && len(svc.To) < svc.NumUnits {
+			logger.Warningf("application %q has %d placement directive(s) for %d unit(s): the last directive will be used for the remaining units", name, len(svc.To), svc.NumUnits)
 		}
-		verifier.verifyPlacement(svc.To)
+		verifier.verifyPlacement(name, svc.To)
 	}
 }
 
-func (verifier *bundleDataVerifier) verifyPlacement(to []string) {
+func (verifier *bundleDataVerifier) verifyPlacement(appName string, to []string) {
+	verifier.verifyUnitTargets(appName, to)
 	for _, p := range to {
 		up, err := ParsePlacement(p)
 		if err != nil {
@@ -532,10 +964,209 @@ func (verifier *bundleDataVerifier) verifyPlacement(to []string) {
 				continue
 			}
 			verifier.machineRefCounts[up.Machine]++
+			if up.ContainerType == "" {
+				verifier.machineUnitRefCounts[up.Machine]++
+			}
+		}
+	}
+}
+
+// verifyUnitTargets checks that the placement directives explicitly
+// specified for the named application do not place more than one unit
+// directly on the same unit, which is almost certainly a mistake.
+// Directives are compared after filling in the implicit unit numbers,
+// so that, for instance, "wordpress" and "wordpress/0" are duplicates
+// when they are the first references to wordpress. Repeats resulting
+// from the replication rule are allowed as they are implicit.
+func (verifier *bundleDataVerifier) verifyUnitTargets(appName string, to []string) {
+	n := len(to)
+	if numUnits := verifier.bd.Applications[appName].NumUnits; n > numUnits {
+		n = numUnits
+	}
+	if n <= 0 {
+		return
+	}
+	unitTargets := make(map[string]int)
+	for _, p := range verifier.appPlacements(appName)[:n] {
+		up, err := ParsePlacement(p)
+		if err != nil || up.Application == "" || up.Unit < 0 || up.ContainerType != "" {
+			continue
+		}
+		target := fmt.Sprintf("%s/%d", up.Application, up.Unit)
+		unitTargets[target]++
+		if unitTargets[target] == 2 {
+			verifier.addErrorf("application %q places more than one unit on unit %q", appName, target)
 		}
 	}
 }
 
+// exactConstraints holds the constraints that a machine must match
+// exactly to satisfy them: a machine has a single architecture,
+// instance type and virtualization type, so two different values
+// cannot both be satisfied by the same machine. Other constraints,
+// such as mem or cores, specify minimum values, and list constraints,
+// such as spaces or tags, can be satisfied together, so differing
+// values are not conflicting.
+var exactConstraints = []string{"arch", "instance-type", "virt-type"}
+
+// verifyConstraintConflicts checks that each application with all its
+// units placed directly on machines defined in the bundle does not
+// specify different values from the machine constraints for a key
+// that must match exactly, as the units could not be deployed as
+// requested. Constraints that are not valid are ignored, as they are
+// reported by verifyApplications and verifyMachines.
+func (verifier *bundleDataVerifier) verifyConstraintConflicts() {
+	for name, app := range verifier.bd.Applications {
+		if app.Constraints == "" || app.NumUnits <= 0 || len(app.To) > app.NumUnits {
+			continue
+		}
+		if verifier.verifyConstraints(app.Constraints) != nil {
+			continue
+		}
+		appCons := parseConstraintValues(app.Constraints)
+		var machineIds []string
+		for _, p := range verifier.appPlacements(name) {
+			up, err := ParsePlacement(p)
+			if err != nil || up.ContainerType != "" || up.Machine == "" || up.Machine == "new" {
+				// The application is not fully placed
+				// directly on machines in the bundle.
+				machineIds = nil
+				break
+			}
+			machineIds = append(machineIds, up.Machine)
+		}
+		seen := make(map[string]bool)
+		for _, id := range machineIds {
+			m := verifier.bd.Machines[id]
+			if seen[id] || m == nil || verifier.verifyConstraints(m.Constraints) != nil {
+				continue
+			}
+			seen[id] = true
+			machineCons := parseConstraintValues(m.Constraints)
+			var conflicts []string
+			for _, key := range exactConstraints {
+				value, ok0 := appCons[key]
+				machineValue, ok1 := machineCons[key]
+				if ok0 && ok1 && value != machineValue {
+					conflicts = append(conflicts, fmt.Sprintf("%s (%q vs %q)", key, value, machineValue))
+				}
+			}
+			if len(conflicts) > 0 {
+				verifier.addErrorf("constraints of application %q conflict with constraints of machine %q: %s", name, id, strings.Join(conflicts, ", "))
+			}
+		}
+	}
+}
+
+// parseConstraintValues returns the values in the given constraints
+// string, indexed by constraint key. Terms not in the key=value form
+// are ignored.
+func parseConstraintValues(cons string) map[string]string {
+	values := make(map[string]string)
+	for _, term := range strings.Fields(cons) {
+		parts := strings.SplitN(term, "=", 2)
+		if len(parts) == 2 {
+			values[parts[0]] = parts[1]
+		}
+	}
+	return values
+}
+
+// verifyNestedContainers checks that no unit is placed in a new
+// container on a unit which is itself inside a container.
+func (verifier *bundleDataVerifier) verifyNestedContainers() {
+	for name, app := range verifier.bd.Applications {
+		if app.NumUnits <= 0 {
+			continue
+		}
+		for i, p := range verifier.appPlacements(name)[:app.NumUnits] {
+			up, err := ParsePlacement(p)
+			if err != nil || up.ContainerType == "" || up.Application == "" {
+				continue
+			}
+			if verifier.unitInContainer(up.Application, up.Unit, make(map[string]bool)) {
+				// Report the directive as written in the bundle.
+				directive := app.To[len(app.To)-1]
+				if i < len(app.To) {
+					directive = app.To[i]
+				}
+				verifier.addErrorf("placement %q in application %q creates a nested container: unit \"%s/%d\" is itself in a container", directive, name, up.Application, up.Unit)
+			}
+		}
+	}
+}
+
+// appPlacements returns the normalized placement directives of
+// the named application, as returned by normalizedPlacements.
+// The results are cached, so that the directives are normalized
+// only once for each application.
+func (verifier *bundleDataVerifier) appPlacements(appName string) []string {
+	if placements, ok := verifier.placements[appName]; ok {
+		return placements
+	}
+	if verifier.placements == nil {
+		verifier.placements = make(map[string][]string)
+	}
+	app := verifier.bd.Applications[appName]
+	placements := normalizedPlacements(app.To, app.NumUnits)
+	verifier.placements[appName] = placements
+	return placements
+}
+
+// unitInContainer reports whether the given unit of the named
+// application is placed inside a container, either directly or by
+// being co-located with a unit inside a container. The visited map
+// is used to detect placement cycles.
+func (verifier *bundleDataVerifier) unitInContainer(appName string, unit int, visited map[string]bool) bool {
+	app := verifier.bd.Applications[appName]
+	if app == nil || unit < 0 || unit >= app.NumUnits {
+		return false
+	}
+	unitName := fmt.Sprintf("%s/%d", appName, unit)
+	if visited[unitName] {
+		return false
+	}
+	visited[unitName] = true
+	up, err := ParsePlacement(verifier.appPlacements(appName)[unit])
+	if err != nil {
+		return false
+	}
+	if up.ContainerType != "" {
+		return true
+	}
+	if up.Application != "" {
+		return verifier.unitInContainer(up.Application, up.Unit, visited)
+	}
+	return false
+}
+
+// unitHost returns a description of the machine hosting the given
+// unit of the named application, either directly or by being
+// co-located with another unit. It reports false if the host cannot
+// be determined. The visited map is used to detect placement cycles.
+func (verifier *bundleDataVerifier) unitHost(appName string, unit int, visited map[string]bool) (string, bool) {
+	app := verifier.bd.Applications[appName]
+	if app == nil || unit < 0 || unit >= app.NumUnits {
+		return "", false
+	}
+	unitName := fmt.Sprintf("%s/%d", appName, unit)
+	if visited[unitName] {
+		return "", false
+	}
+	visited[unitName] = true
+	up, err := ParsePlacement(verifier.appPlacements(appName)[unit])
+	if err != nil {
+		return "", false
+	}
+	switch {
+	case up.Machine == "new":
+		return fmt.Sprintf("the new machine of unit %q", unitName), true
+	case up.Machine != "":
+		return fmt.Sprintf("machine %q", up.Machine), true
+	}
+	return verifier.unitHost(up.Application, up.Unit, visited)
+}
+
 func (verifier *bundleDataVerifier) getCharmMetaForApplication(appName string) (*Meta, error) {
 	svc, ok := verifier.bd.Applications[appName]
 	if !ok {
@@ -548,34 +1179,61 @@ func (verifier *bundleDataVerifier) getCharmMetaForApplication(appName string) (
 	return ch.Meta(), nil
 }
 
+// relationInterface identifies the interface used by a relation
+// between two applications.
+type relationInterface struct {
+	application0, application1 string
+	iface                      string
+}
+
 func (verifier *bundleDataVerifier) verifyRelations() {
 	seen := make(map[[2]endpoint]bool)
+	// interfaces holds the first relation found for each
+	// application pair and interface.
+	interfaces := make(map[relationInterface][]string)
+	// related holds the applications related to each application
+	// by a valid relation.
+	related := make(map[string][]string)
+	// unverified holds the applications named by relations that
+	// could not be verified because of an error already reported.
+	unverified := make(map[string]bool)
 	for _, relPair := range verifier.bd.Relations {
 		if len(relPair) != 2 {
 			verifier.addErrorf("relation %q has %d endpoint(s), not 2", relPair, len(relPair))
 			continue
 		}
 		var epPair [2]endpoint
-		relParseErr := false
+		var appNames []string
+		validEndpoints := true
 		for i, svcRel := range relPair {
+			// Each endpoint is checked independently, and at most
+			// one error is reported for each of them.
 			ep, err := parseEndpoint(svcRel)
 			if err != nil {
 				verifier.addError(err)
-				relParseErr = true
+				validEndpoints = false
 				continue
 			}
+			appNames = append(appNames, ep.application)
 			if _, ok := verifier.bd.Applications[ep.application]; !ok {
 				verifier.addErrorf("relation %q refers to application %q not defined in this bundle", relPair, ep.application)
+				validEndpoints = false
+				continue
 			}
 			epPair[i] = ep
 		}
-		if relParseErr {
-			// We failed to parse at least one relation, so don't
-			// bother checking further.
+		if !validEndpoints {
+			// At least one endpoint is invalid, so don't bother
+			// checking further: any other error would only be
+			// a consequence of the ones already reported.
+			for _, name := range appNames {
+				unverified[name] = true
+			}
 			continue
 		}
 		if epPair[0].application == epPair[1].application {
 			verifier.addErrorf("relation %q relates an application to itself", relPair)
+			unverified[epPair[0].application] = true
 		}
 		// Resolve endpoint relations if necessary and we have
 		// the necessary charm information.
@@ -583,6 +1241,8 @@ func (verifier *bundleDataVerifier) verifyRelations() {
 			iep0, iep1, err := inferEndpoints(epPair[0], epPair[1], verifier.getCharmMetaForApplication)
 			if err != nil {
 				verifier.addErrorf("cannot infer endpoint between %s and %s: %v", epPair[0], epPair[1], err)
+				unverified[epPair[0].application] = true
+				unverified[epPair[1].application] = true
 			} else {
 				// Change the endpoints that get recorded
 				// as seen, so we'll diagnose a duplicate
@@ -598,16 +1258,72 @@ func (verifier *bundleDataVerifier) verifyRelations() {
 		if epPair[1].less(epPair[0]) {
 			epPair[1], epPair[0] = epPair[0], epPair[1]
 		}
-		if _, ok := seen[epPair]; ok {
+		duplicate := seen[epPair]
+		if duplicate {
 			verifier.addErrorf("relation %q is defined more than once", relPair)
 		}
 		if verifier.charms != nil && epPair[0].relation != "" && epPair[1].relation != "" {
 			// We have charms to verify against, and the
 			// endpoint has been fully specified or inferred.
-			verifier.verifyRelation(epPair[0], epPair[1])
+			iface := verifier.verifyRelation(epPair[0], epPair[1])
+			// Only valid relations count when checking that subordinates
+			// are related to a principal. Relations involving unknown
+			// charms also count, as an error is already reported for them.
+			if iface != "" || !verifier.hasCharm(epPair[0].application) || !verifier.hasCharm(epPair[1].application) {
+				related[epPair[0].application] = append(related[epPair[0].application], epPair[1].application)
+				related[epPair[1].application] = append(related[epPair[1].application], epPair[0].application)
+			}
+			if iface != "" && !duplicate {
+				key := relationInterface{epPair[0].application, epPair[1].application, iface}
+				if prev, ok := interfaces[key]; ok {
+					verifier.addErrorf("relations %q and %q both relate %q to %q on interface %q", prev, relPair, key.application0, key.application1, iface)
+				} else {
+					interfaces[key] = relPair
+				}
+			}
 		}
 		seen[epPair] = true
 	}
+	verifier.verifySubordinatesRelated(related, unverified)
+}
+
+// hasCharm reports whether the charm used by
+// the named application is known to the verifier.
+func (verifier *bundleDataVerifier) hasCharm(appName string) bool {
+	app := verifier.bd.Applications[appName]
+	return app != nil && verifier.charms[app.Charm] != nil
+}
+
+// verifySubordinatesRelated checks that each subordinate application
+// is related to at least one principal application, given the
+// applications related to each application. Applications whose
+// charm is unknown are assumed to be principals, as an error is
+// already reported for them by verifyApplications. Subordinates
+// named by an unverified relation are not checked, as the relation
+// error has already been reported.
+func (verifier *bundleDataVerifier) verifySubordinatesRelated(related map[string][]string, unverified map[string]bool) {
+	if verifier.charms == nil {
+		return
+	}
+	isSubordinate := func(appName string) bool {
+		ch := verifier.charms[verifier.bd.Applications[appName].Charm]
+		return ch != nil && ch.Meta().Subordinate
+	}
+	for name := range verifier.bd.Applications {
+		if !isSubordinate(name) || unverified[name] {
+			continue
+		}
+		hasPrincipal := false
+		for _, other := range related[name] {
+			if !isSubordinate(other) {
+				hasPrincipal = true
+				break
+			}
+		}
+		if !hasPrincipal {
+			verifier.addErrorf("subordinate application %q is not related to any principal application", name)
+		}
+	}
 }
 
 func (verifier *bundleDataVerifier) verifyEndpointBindings() {
@@ -645,19 +1361,21 @@ var infoRelation = Relation{
 // It checks that both endpoints of the relation are
 // defined, and that the relationship is correctly
 // symmetrical (provider to requirer) and shares
-// the same interface.
-func (verifier *bundleDataVerifier) verifyRelation(ep0, ep1 endpoint) {
+// the same interface. It returns the interface of
+// the relation, or the empty string if the relation
+// is not valid.
+func (verifier *bundleDataVerifier) verifyRelation(ep0, ep1 endpoint) string {
 	svc0 := verifier.bd.Applications[ep0.application]
 	svc1 := verifier.bd.Applications[ep1.application]
 	if svc0 == nil || svc1 == nil || svc0 == svc1 {
 		// An error will be produced by verifyRelations for this case.
-		return
+		return ""
 	}
 	charm0 := verifier.charms[svc0.Charm]
 	charm1 := verifier.charms[svc1.Charm]
 	if charm0 == nil || charm1 == nil {
 		// An error will be produced by verifyApplications for this case.
-		return
+		return ""
 	}
 	relProv0, okProv0 := charm0.Meta().Provides[ep0.relation]
 	// The juju-info relation is provided implicitly by every
@@ -691,17 +1409,19 @@ func (verifier *bundleDataVerifier) verifyRelation(ep0, ep1 endpoint) {
 		epProv, epReq = ep1, ep0
 	case okProv0 && okProv1:
 		verifier.addErrorf("relation %q to %q relates provider to provider", ep0, ep1)
-		return
+		return ""
 	case okReq0 && okReq1:
 		verifier.addErrorf("relation %q to %q relates requirer to requirer", ep0, ep1)
-		return
+		return ""
 	default:
 		// Errors were added above.
-		return
+		return ""
 	}
 	if relProv.Interface != relReq.Interface {
 		verifier.addErrorf("mismatched interface between %q and %q (%q vs %q)", epProv, epReq, relProv.Interface, relReq.Interface)
+		return ""
 	}
+	return relProv.Interface
 }
 
 // verifyOptions verifies that the options are correctly defined
@@ -873,6 +1593,24 @@ func ParsePlacement(p string) (*UnitPlacement, error) {
 	return &up, nil
 }
 
+// String returns the placement directive described by up,
+// in the format accepted by ParsePlacement.
+func (up *UnitPlacement) String() string {
+	var p string
+	switch {
+	case up.Application == "":
+		p = up.Machine
+	case up.Unit >= 0:
+		p = fmt.Sprintf("%s/%d", up.Application, up.Unit)
+	default:
+		p = up.Application
+	}
+	if up.ContainerType != "" {
+		p = up.ContainerType + ":" + p
+	}
+	return p
+}
+
 // inferEndpoints infers missing relation names from the given endpoint
 // specifications, using the given get function to retrieve charm
 // data if necessary. It returns the fully specified endpoints.
@@ -979,3 +1717,12 @@ func possibleEndpoints(epSpec endpoint, get func(svc string) (*Meta, error)) ([]
 	})
 	return eps, nil
 }
+
+// quoteNames returns the given names quoted and separated by commas.
+func quoteNames(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = fmt.Sprintf("%q", name)
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -12,8 +12,11 @@ import (
 
 	"github.com/juju/testing"
 	jc "github.com/juju/testing/checkers"
+	"github.com/juju/utils/fs"
+	"github.com/juju/version"
 	gc "gopkg.in/check.v1"
 	"gopkg.in/mgo.v2/bson"
+	"gopkg.in/yaml.v2"
 
 	"gopkg.in/juju/charm.v6-unstable"
 )
@@ -76,6 +79,10 @@ tags:
 description: |
     Everything is awesome. Everything is cool when we work as a team.
     Lovely day.
+docs: https://example.com/mediawiki-bundle
+annotations:
+    environment: production
+    owner: wiki-team
 `
 
 var parseTests = []struct {
@@ -152,6 +159,11 @@ var parseTests = []struct {
 		Description: `Everything is awesome. Everything is cool when we work as a team.
 Lovely day.
 `,
+		Docs: "https://example.com/mediawiki-bundle",
+		Annotations: map[string]string{
+			"environment": "production",
+			"owner":       "wiki-team",
+		},
 	},
 }, {
 	about: "relations specified with hyphens",
@@ -246,6 +258,56 @@ func (*bundleDataSuite) TestCodecRoundTrip(c *gc.C) {
 	}
 }
 
+func (*bundleDataSuite) TestDocsRoundTrip(c *gc.C) {
+	bd := &charm.BundleData{
+		Applications: map[string]*charm.ApplicationSpec{
+			"wordpress": {Charm: "wordpress", NumUnits: 1},
+		},
+		Docs: "https://example.com/wordpress-bundle",
+	}
+	data, err := yaml.Marshal(bd)
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(data), jc.Contains, "docs: https://example.com/wordpress-bundle\n")
+	readBD, err := charm.ReadBundleData(strings.NewReader(string(data)))
+	c.Assert(err, gc.IsNil)
+	c.Assert(readBD, jc.DeepEquals, bd)
+
+	// The field is omitted when empty.
+	bd.Docs = ""
+	data, err = yaml.Marshal(bd)
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(data), gc.Not(jc.Contains), "docs")
+	readBD, err = charm.ReadBundleData(strings.NewReader(string(data)))
+	c.Assert(err, gc.IsNil)
+	c.Assert(readBD, jc.DeepEquals, bd)
+}
+
+func (*bundleDataSuite) TestAnnotationsRoundTrip(c *gc.C) {
+	bd := &charm.BundleData{
+		Applications: map[string]*charm.ApplicationSpec{
+			"wordpress": {Charm: "wordpress", NumUnits: 1},
+		},
+		Annotations: map[string]string{
+			"owner":       "bob",
+			"environment": "staging",
+		},
+	}
+	for i, codec := range codecs {
+		c.Logf("codec %d: %v", i, codec.Name)
+		data, err := codec.Marshal(bd)
+		c.Assert(err, gc.IsNil)
+		var readBD charm.BundleData
+		err = codec.Unmarshal(data, &readBD)
+		c.Assert(err, gc.IsNil)
+		c.Assert(&readBD, jc.DeepEquals, bd)
+	}
+	data, err := yaml.Marshal(bd)
+	c.Assert(err, gc.IsNil)
+	readBD, err := charm.ReadBundleData(strings.NewReader(string(data)))
+	c.Assert(err, gc.IsNil)
+	c.Assert(readBD, jc.DeepEquals, bd)
+}
+
 func (*bundleDataSuite) TestParseLocalWithSeries(c *gc.C) {
 	path := "internal/test-charm-repo/quanta/riak"
 	data := fmt.Sprintf(`
@@ -390,7 +452,7 @@ relations:
 		`negative number of units specified on application "mediawiki"`,
 		`missing resource name on application "mediawiki"`,
 		`the charm URL for application "postgres" has a series which does not match, please remove the series from the URL`,
-		`too many units specified in unit placement for application "mysql"`,
+		`too many units specified in unit placement for application "mysql": 5 placement directive(s) for 2 unit(s)`,
 		`placement "nowhere/3" refers to an application not defined in this bundle`,
 		`placement "mediawiki/0" specifies a unit greater than the -4 unit(s) started by the target application`,
 		`placement "2" refers to a machine not defined in this bundle`,
@@ -405,6 +467,153 @@ relations:
 }, {
 	about: "mediawiki should be ok",
 	data:  mediawikiBundle,
+}, {
+	about: "application series overriding the bundle series",
+	data: `
+series: trusty
+applications:
+    wordpress:
+        charm: wordpress
+        series: xenial
+    mysql:
+        charm: cs:xenial/mysql
+        series: xenial
+    memcached:
+        charm: cs:trusty/memcached
+        series: xenial
+    varnish:
+        charm: varnish
+        series: "bad series"
+`,
+	errors: []string{
+		`the charm URL for application "memcached" has a series which does not match, please remove the series from the URL`,
+		`application "varnish" declares an invalid series "bad series"`,
+	},
+}, {
+	about: "empty bundle",
+	data: `
+# Nothing to see here.
+`,
+	errors: []string{
+		`at least one application must be specified`,
+	},
+}, {
+	about: "bundle with machines but no applications",
+	data: `
+machines:
+    0:
+`,
+	errors: []string{
+		`at least one application must be specified`,
+		`machine "0" is not referred to by a placement directive`,
+	},
+}, {
+	about: "nested container placements",
+	data: `
+applications:
+    wordpress:
+        charm: wordpress
+        num_units: 2
+        to: ["lxc:0", 1]
+    mysql:
+        charm: mysql
+        num_units: 2
+        to: ["lxc:wordpress"]
+    memcached:
+        charm: memcached
+        num_units: 1
+        to: [wordpress/0]
+    varnish:
+        charm: varnish
+        num_units: 2
+        to: ["kvm:memcached/0", "lxc:varnish/0"]
+machines:
+    0:
+    1:
+`,
+	errors: []string{
+		`placement "lxc:wordpress" in application "mysql" creates a nested container: unit "wordpress/0" is itself in a container`,
+		`placement "kvm:memcached/0" in application "varnish" creates a nested container: unit "memcached/0" is itself in a container`,
+		`placement "lxc:varnish/0" in application "varnish" creates a nested container: unit "varnish/0" is itself in a container`,
+	},
+}, {
+	about: "bad relation name on an existing application",
+	data: `
+applications:
+    wordpress:
+        charm: wordpress
+    mysql:
+        charm: mysql
+relations:
+    - ["wordpress:BadName", "mysql:db"]
+`,
+	errors: []string{
+		`invalid relation syntax "wordpress:BadName"`,
+	},
+}, {
+	about: "bad relation name on an undefined application",
+	data: `
+applications:
+    wordpress:
+        charm: wordpress
+    mysql:
+        charm: mysql
+relations:
+    - ["unknown:BadName", "mysql:db"]
+    - ["unknown:db", "mysql:db"]
+`,
+	errors: []string{
+		`invalid relation syntax "unknown:BadName"`,
+		`relation ["unknown:db" "mysql:db"] refers to application "unknown" not defined in this bundle`,
+	},
+}, {
+	about: "invalid relations are not reported as duplicates",
+	data: `
+applications:
+    wordpress:
+        charm: wordpress
+relations:
+    - ["unknown:db", "wordpress:db"]
+    - ["wordpress:db", "unknown:db"]
+    - ["wordpress:BadName", "wordpress:db"]
+`,
+	errors: []string{
+		`invalid relation syntax "wordpress:BadName"`,
+		`relation ["unknown:db" "wordpress:db"] refers to application "unknown" not defined in this bundle`,
+		`relation ["wordpress:db" "unknown:db"] refers to application "unknown" not defined in this bundle`,
+	},
+}, {
+	about: "duplicate explicit unit placements",
+	data: `
+applications:
+    wordpress:
+        charm: wordpress
+        num_units: 2
+    mysql:
+        charm: mysql
+        num_units: 3
+        to: ["wordpress/0", "wordpress/0", "wordpress/0"]
+    varnish:
+        charm: varnish
+        num_units: 2
+        to: ["wordpress", "wordpress/0"]
+    haproxy:
+        charm: haproxy
+        num_units: 2
+        to: ["wordpress", "wordpress"]
+    memcached:
+        charm: memcached
+        num_units: 3
+        to: ["lxd:wordpress/1", "lxd:wordpress/1", "wordpress/1"]
+    logging:
+        charm: logging
+        num_units: 3
+        to: ["wordpress/1"]
+`,
+	errors: []string{
+		`application "mysql" places more than one unit on unit "wordpress/0"`,
+		`application "varnish" places more than one unit on unit "wordpress/0"`,
+	},
 }}
 
 func (*bundleDataSuite) TestVerifyErrors(c *gc.C) {
@@ -456,6 +665,198 @@ func assertVerifyErrors(c *gc.C, bundleData string, charms map[string]charm.Char
 	c.Assert(errStrings, jc.DeepEquals, expectErrors)
 }
 
+func (*bundleDataSuite) TestVerifyPartialPlacementWarning(c *gc.C) {
+	bd, err := charm.ReadBundleData(strings.NewReader(`
+applications:
+    wordpress:
+        charm: wordpress
+        num_units: 3
+        to: [0]
+    mysql:
+        charm: mysql
+        num_units: 2
+        to: [0, 0]
+    haproxy:
+        charm: haproxy
+        num_units: 2
+machines:
+    0:
+`))
+	c.Assert(err, gc.IsNil)
+	err = bd.Verify(nil, nil)
+	c.Assert(err, gc.IsNil)
+	tlog := c.GetTestLog()
+	c.Assert(tlog, gc.Matches, `(.|\n)*WARNING juju.charm application "wordpress" has 1 placement directive\(s\) for 3 unit\(s\): the last directive will be used for the remaining units(.|\n)*`)
+	c.Assert(tlog, gc.Not(gc.Matches), `(.|\n)*application "(mysql|haproxy)" has(.|\n)*`)
+}
+
+func (*bundleDataSuite) TestVerifyContainerOnlyMachineConstraintsWarning(c *gc.C) {
+	bd, err := charm.ReadBundleData(strings.NewReader(`
+applications:
+    wordpress:
+        charm: wordpress
+        num_units: 2
+        to: ["lxc:0", "lxc:1"]
+    mysql:
+        charm: mysql
+        num_units: 1
+        to: [1]
+machines:
+    0:
+        constraints: "mem=4G instance-type=m1.large root-disk=20G"
+    1:
+        constraints: "instance-type=m1.small"
+`))
+	c.Assert(err, gc.IsNil)
+	err = bd.Verify(nil, nil)
+	c.Assert(err, gc.IsNil)
+	tlog := c.GetTestLog()
+	c.Assert(tlog, gc.Matches, `(.|\n)*WARNING juju.charm machine "0" only hosts containers but has constraint "instance-type=m1.large", which does not apply to containers(.|\n)*`)
+	c.Assert(tlog, gc.Matches, `(.|\n)*WARNING juju.charm machine "0" only hosts containers but has constraint "root-disk=20G", which does not apply to containers(.|\n)*`)
+	c.Assert(tlog, gc.Not(gc.Matches), `(.|\n)*constraint "mem=4G"(.|\n)*`)
+	// Machine 1 also hosts a unit directly.
+	c.Assert(tlog, gc.Not(gc.Matches), `(.|\n)*machine "1" only hosts containers(.|\n)*`)
+}
+
+func (*bundleDataSuite) TestVerifyConstraintConflicts(c *gc.C) {
+	bd, err := charm.ReadBundleData(strings.NewReader(`
+applications:
+    wordpress:
+        charm: wordpress
+        num_units: 2
+        to: [0, 1]
+        constraints: "arch=amd64 mem=4G"
+    mysql:
+        charm: mysql
+        num_units: 2
+        to: [1]
+        constraints: "arch=amd64 cores=4 instance-type=m1.large"
+    haproxy:
+        charm: haproxy
+        num_units: 2
+        to: [0, "lxc:1"]
+        constraints: "arch=ppc64el"
+machines:
+    0:
+        constraints: "arch=amd64 mem=4G"
+    1:
+        constraints: "arch=arm64 mem=8G cores=8 instance-type=m1.small"
+`))
+	c.Assert(err, gc.IsNil)
+	err = bd.Verify(nil, nil)
+	c.Assert(err, gc.FitsTypeOf, (*charm.VerificationError)(nil))
+	var errStrings []string
+	for _, err := range err.(*charm.VerificationError).Errors {
+		errStrings = append(errStrings, err.Error())
+	}
+	sort.Strings(errStrings)
+	// Minimum-style constraints such as mem and cores never conflict,
+	// and the haproxy application is not placed entirely on machines.
+	c.Assert(errStrings, jc.DeepEquals, []string{
+		`constraints of application "mysql" conflict with constraints of machine "1": arch ("amd64" vs "arm64"), instance-type ("m1.large" vs "m1.small")`,
+		`constraints of application "wordpress" conflict with constraints of machine "1": arch ("amd64" vs "arm64")`,
+	})
+}
+
+func (*bundleDataSuite) TestVerifyCompatibleConstraints(c *gc.C) {
+	bd, err := charm.ReadBundleData(strings.NewReader(`
+applications:
+    wordpress:
+        charm: wordpress
+        num_units: 1
+        to: [0]
+        constraints: "mem=4G"
+machines:
+    0:
+        constraints: "mem=8G"
+`))
+	c.Assert(err, gc.IsNil)
+	err = bd.Verify(nil, nil)
+	c.Assert(err, gc.IsNil)
+	c.Assert(c.GetTestLog(), gc.Not(gc.Matches), `(.|\n)*conflict(.|\n)*`)
+}
+
+const rewriteURLsBundle = `
+applications:
+    wordpress:
+        charm: cs:trusty/wordpress-42
+    mysql:
+        charm: mysql
+    haproxy:
+        charm: cs:~bob/xenial/haproxy
+    local:
+        charm: ./local-charm
+    wordpress-stable:
+        charm: trusty/wordpress
+`
+
+func (*bundleDataSuite) TestRewriteURLs(c *gc.C) {
+	bd, err := charm.ReadBundleData(strings.NewReader(rewriteURLsBundle))
+	c.Assert(err, gc.IsNil)
+	err = bd.RewriteURLs(func(curl *charm.URL) (*charm.URL, error) {
+		if curl.User == "" {
+			newURL := *curl
+			newURL.User = "acme"
+			return &newURL, nil
+		}
+		return curl, nil
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(bd.RequiredCharms(), jc.DeepEquals, []string{
+		"./local-charm",
+		"cs:~acme/mysql",
+		"cs:~acme/trusty/wordpress",
+		"cs:~acme/trusty/wordpress-42",
+		"cs:~bob/xenial/haproxy",
+	})
+}
+
+func (*bundleDataSuite) TestRewriteURLsIdentity(c *gc.C) {
+	bd, err := charm.ReadBundleData(strings.NewReader(rewriteURLsBundle))
+	c.Assert(err, gc.IsNil)
+	err = bd.RewriteURLs(func(curl *charm.URL) (*charm.URL, error) {
+		newURL := *curl
+		return &newURL, nil
+	})
+	c.Assert(err, gc.IsNil)
+	c.Assert(bd.RequiredCharms(), jc.DeepEquals, []string{
+		"./local-charm",
+		"cs:trusty/wordpress-42",
+		"cs:~bob/xenial/haproxy",
+		"mysql",
+		"trusty/wordpress",
+	})
+}
+
+func (*bundleDataSuite) TestRewriteURLsErrors(c *gc.C) {
+	bd, err := charm.ReadBundleData(strings.NewReader(rewriteURLsBundle))
+	c.Assert(err, gc.IsNil)
+	orig := bd.RequiredCharms()
+
+	err = bd.RewriteURLs(func(curl *charm.URL) (*charm.URL, error) {
+		if curl.Name == "wordpress" {
+			return nil, fmt.Errorf("no way")
+		}
+		return curl.WithRevision(1), nil
+	})
+	c.Assert(err, gc.ErrorMatches, `cannot rewrite charm URL in application "wordpress": no way`)
+	c.Assert(bd.RequiredCharms(), jc.DeepEquals, orig)
+
+	err = bd.RewriteURLs(func(curl *charm.URL) (*charm.URL, error) {
+		newURL := *curl
+		newURL.Name = "Bad_Name"
+		return &newURL, nil
+	})
+	c.Assert(err, gc.ErrorMatches, `cannot rewrite charm URL in application "haproxy": .*`)
+	c.Assert(bd.RequiredCharms(), jc.DeepEquals, orig)
+
+	err = bd.RewriteURLs(func(curl *charm.URL) (*charm.URL, error) {
+		return nil, nil
+	})
+	c.Assert(err, gc.ErrorMatches, `cannot rewrite charm URL in application "haproxy": no URL returned`)
+	c.Assert(bd.RequiredCharms(), jc.DeepEquals, orig)
+}
+
 func (*bundleDataSuite) TestVerifyCharmURL(c *gc.C) {
 	bd, err := charm.ReadBundleData(strings.NewReader(mediawikiBundle))
 	c.Assert(err, gc.IsNil)
@@ -480,7 +881,7 @@ func (*bundleDataSuite) TestVerifyLocalCharm(c *gc.C) {
 	c.Assert(err, gc.IsNil)
 	bundleDir := c.MkDir()
 	relativeCharmDir := filepath.Join(bundleDir, "charm")
-	err = os.MkdirAll(relativeCharmDir, 0700)
+	err = fs.Copy(charmDirPath(c, "mysql"), relativeCharmDir)
 	c.Assert(err, jc.ErrorIsNil)
 	for i, u := range []string{
 		"wordpress",
@@ -490,7 +891,7 @@ func (*bundleDataSuite) TestVerifyLocalCharm(c *gc.C) {
 		"precise/wordpress-2",
 		"local:foo",
 		"local:foo-45",
-		c.MkDir(),
+		cloneDir(c, charmDirPath(c, "mysql")),
 		"./charm",
 	} {
 		c.Logf("test %d: %s", i, u)
@@ -500,6 +901,62 @@ func (*bundleDataSuite) TestVerifyLocalCharm(c *gc.C) {
 	}
 }
 
+func (*bundleDataSuite) TestVerifyLocalCharmNotCharmDir(c *gc.C) {
+	bd, err := charm.ReadBundleData(strings.NewReader(`
+applications:
+    wordpress:
+        charm: ../charms/wordpress
+        num_units: 1
+    mysql:
+        charm: ../charms/mysql
+        num_units: 1
+`))
+	c.Assert(err, gc.IsNil)
+	repoDir := c.MkDir()
+	bundleDir := filepath.Join(repoDir, "bundle")
+	err = os.Mkdir(bundleDir, 0755)
+	c.Assert(err, gc.IsNil)
+	err = os.MkdirAll(filepath.Join(repoDir, "charms", "mysql"), 0755)
+	c.Assert(err, gc.IsNil)
+	err = fs.Copy(charmDirPath(c, "wordpress"), filepath.Join(repoDir, "charms", "wordpress"))
+	c.Assert(err, gc.IsNil)
+
+	err = bd.VerifyLocal(bundleDir, nil, nil)
+	c.Assert(err, gc.FitsTypeOf, (*charm.VerificationError)(nil))
+	c.Assert(err.(*charm.VerificationError).Errors, gc.HasLen, 1)
+	c.Assert(err, gc.ErrorMatches, `charm path in application "mysql" does not point to a charm directory: .*/charms/mysql`)
+	c.Assert(bd.RequiredCharms(), jc.DeepEquals, []string{"../charms/mysql", "../charms/wordpress"})
+}
+
+func (*bundleDataSuite) TestVerifyLocalWithCharms(c *gc.C) {
+	bd, err := charm.ReadBundleData(strings.NewReader(`
+applications:
+    wordpress:
+        charm: ./wordpress
+        num_units: 1
+    mysql:
+        charm: ./mysql
+        num_units: 1
+relations:
+    - ["wordpress:db", "mysql:server"]
+    - ["wordpress:cache", "mysql:cache"]
+`))
+	c.Assert(err, gc.IsNil)
+	bundleDir := c.MkDir()
+	charms := make(map[string]charm.Charm)
+	for _, name := range []string{"wordpress", "mysql"} {
+		path := filepath.Join(bundleDir, name)
+		err := fs.Copy(charmDirPath(c, name), path)
+		c.Assert(err, gc.IsNil)
+		charms["./"+name], err = charm.ReadCharmDir(path)
+		c.Assert(err, gc.IsNil)
+	}
+	err = bd.VerifyLocalWithCharms(bundleDir, nil, nil, charms)
+	c.Assert(err, gc.FitsTypeOf, (*charm.VerificationError)(nil))
+	c.Assert(err.(*charm.VerificationError).Errors, gc.HasLen, 1)
+	c.Assert(err, gc.ErrorMatches, `charm "./mysql" used by application "mysql" does not define relation "cache"`)
+}
+
 func (s *bundleDataSuite) TestVerifyBundleUsingJujuInfoRelation(c *gc.C) {
 	err := s.testPrepareAndMutateBeforeVerifyWithCharms(c, nil)
 	c.Assert(err, gc.IsNil)
@@ -558,6 +1015,96 @@ func (*bundleDataSuite) TestRequiredCharms(c *gc.C) {
 	c.Assert(reqCharms, gc.DeepEquals, []string{"cs:precise/mediawiki-10", "cs:precise/mysql-28"})
 }
 
+func (*bundleDataSuite) TestRequiredTerms(c *gc.C) {
+	bd, err := charm.ReadBundleData(strings.NewReader(`
+applications:
+    terms:
+        charm: cs:terms
+    other-terms:
+        charm: cs:other-terms
+    wordpress:
+        charm: cs:wordpress
+    missing:
+        charm: cs:missing
+`))
+	c.Assert(err, gc.IsNil)
+	otherMeta, err := charm.ReadMeta(strings.NewReader(`
+name: other-terms
+summary: s
+description: d
+terms: ["term2", "term4/2"]
+`))
+	c.Assert(err, gc.IsNil)
+	charms := map[string]charm.Charm{
+		"cs:terms":       readCharmDir(c, "terms"),
+		"cs:other-terms": testCharmImpl{meta: otherMeta},
+		"cs:wordpress":   readCharmDir(c, "wordpress"),
+	}
+	c.Assert(bd.RequiredTerms(charms), jc.DeepEquals, []string{
+		"owner/term3/1",
+		"term1/1",
+		"term2",
+		"term4/2",
+	})
+
+	delete(charms, "cs:terms")
+	delete(charms, "cs:other-terms")
+	terms := bd.RequiredTerms(charms)
+	c.Assert(terms, gc.NotNil)
+	c.Assert(terms, gc.HasLen, 0)
+}
+
+func (*bundleDataSuite) TestVerifyJujuVersion(c *gc.C) {
+	bd, err := charm.ReadBundleData(strings.NewReader(`
+applications:
+    new:
+        charm: cs:new
+    newer:
+        charm: cs:newer
+    old:
+        charm: cs:old
+    any:
+        charm: cs:any
+    missing:
+        charm: cs:missing
+`))
+	c.Assert(err, gc.IsNil)
+	charmWithMinVersion := func(name, minver string) charm.Charm {
+		meta := &charm.Meta{
+			Name:        name,
+			Summary:     name,
+			Description: name,
+		}
+		if minver != "" {
+			meta.MinJujuVersion = version.MustParse(minver)
+		}
+		return testCharmImpl{meta: meta}
+	}
+	charms := map[string]charm.Charm{
+		"cs:new":   charmWithMinVersion("new", "2.0.1"),
+		"cs:newer": charmWithMinVersion("newer", "2.1-beta1"),
+		"cs:old":   charmWithMinVersion("old", "1.25.0"),
+		"cs:any":   charmWithMinVersion("any", ""),
+	}
+
+	err = bd.VerifyJujuVersion(charms, version.MustParse("2.1.0"))
+	c.Assert(err, gc.IsNil)
+	err = bd.VerifyJujuVersion(charms, version.MustParse("2.0.1"))
+	c.Assert(err, gc.ErrorMatches, `charm "cs:newer" used by application "newer" requires Juju 2.1-beta1 or later, but the target version is 2.0.1`)
+
+	err = bd.VerifyJujuVersion(charms, version.MustParse("1.25.6"))
+	c.Assert(err, gc.FitsTypeOf, (*charm.VerificationError)(nil))
+	var errStrings []string
+	for _, err := range err.(*charm.VerificationError).Errors {
+		errStrings = append(errStrings, err.Error())
+	}
+	sort.Strings(errStrings)
+	c.Assert(errStrings, jc.DeepEquals, []string{
+		`charm "cs:new" used by application "new" requires Juju 2.0.1 or later, but the target version is 1.25.6`,
+		`charm "cs:newer" used by application "newer" requires Juju 2.1-beta1 or later, but the target version is 1.25.6`,
+	})
+}
+
 // testCharm returns a charm with the given name
 // and relations. The relations are specified as
 // a string of the form:
@@ -723,24 +1270,42 @@ relations:
 		`relation ["unknown:prova" "application2:blah"] refers to application "unknown" not defined in this bundle`,
 	},
 }, {
-	about: "equal applications",
+	about: "undefined applications with relations to be inferred",
 	data: `
 applications:
     application1:
         charm: "test"
-    application2:
-        charm: "test"
 relations:
-    - ["application2:prova", "application2:reqa"]
+    - ["unknown", "application1"]
+    - ["application1:BadName", "unknown"]
 `,
 	charms: map[string]charm.Charm{
 		"test": testCharm("test", "prova:a provb:b | reqa:a reqb:b"),
 	},
 	errors: []string{
-		`relation ["application2:prova" "application2:reqa"] relates an application to itself`,
+		`invalid relation syntax "application1:BadName"`,
+		`relation ["application1:BadName" "unknown"] refers to application "unknown" not defined in this bundle`,
+		`relation ["unknown" "application1"] refers to application "unknown" not defined in this bundle`,
 	},
 }, {
-	about: "provider to provider relation",
+	about: "equal applications",
+	data: `
+applications:
+    application1:
+        charm: "test"
+    application2:
+        charm: "test"
+relations:
+    - ["application2:prova", "application2:reqa"]
+`,
+	charms: map[string]charm.Charm{
+		"test": testCharm("test", "prova:a provb:b | reqa:a reqb:b"),
+	},
+	errors: []string{
+		`relation ["application2:prova" "application2:reqa"] relates an application to itself`,
+	},
+}, {
+	about: "provider to provider relation",
 	data: `
 applications:
     application1:
@@ -890,6 +1455,43 @@ relations:
 	errors: []string{
 		`relation ["application1" "application2"] is defined more than once`,
 	},
+}, {
+	about: "relations between the same applications on different interfaces",
+	data: `
+applications:
+    application1:
+        charm: "provider"
+    application2:
+        charm: "requirer"
+relations:
+    - ["application1:prova", "application2:reqa"]
+    - ["application1:provb", "application2:reqb"]
+`,
+	charms: map[string]charm.Charm{
+		"provider": testCharm("provider", "prova:a provb:b | "),
+		"requirer": testCharm("requirer", "| reqa:a reqb:b"),
+	},
+}, {
+	about: "relations between the same applications on the same interface",
+	data: `
+applications:
+    application1:
+        charm: "provider"
+    application2:
+        charm: "requirer"
+relations:
+    - ["application1:prova", "application2:reqa"]
+    - ["application2:reqa2", "application1:prova2"]
+    - ["application1:prova", "application2:reqa"]
+`,
+	charms: map[string]charm.Charm{
+		"provider": testCharm("provider", "prova:a prova2:a | "),
+		"requirer": testCharm("requirer", "| reqa:a reqa2:a"),
+	},
+	errors: []string{
+		`relation ["application1:prova" "application2:reqa"] is defined more than once`,
+		`relations ["application1:prova" "application2:reqa"] and ["application2:reqa2" "application1:prova2"] both relate "application1" to "application2" on interface "a"`,
+	},
 }, {
 	about: "configuration options specified",
 	data: `
@@ -979,6 +1581,7 @@ applications:
 	},
 	errors: []string{
 		`application "testsub" is subordinate but has non-zero num_units`,
+		`subordinate application "testsub" is not related to any principal application`,
 	},
 }, {
 	about: "subordinate charm with more than one unit",
@@ -993,6 +1596,7 @@ applications:
 	},
 	errors: []string{
 		`application "testsub" is subordinate but has non-zero num_units`,
+		`subordinate application "testsub" is not related to any principal application`,
 	},
 }, {
 	about: "subordinate charm with to-clause",
@@ -1009,7 +1613,91 @@ machines:
 	},
 	errors: []string{
 		`application "testsub" is subordinate but specifies unit placement`,
-		`too many units specified in unit placement for application "testsub"`,
+		`subordinate application "testsub" is not related to any principal application`,
+		`too many units specified in unit placement for application "testsub": 1 placement directive(s) for 0 unit(s)`,
+	},
+}, {
+	about: "subordinate charm related to a principal",
+	data: `
+applications:
+    wordpress:
+        charm: "wordpress"
+        num_units: 1
+    logging:
+        charm: "logging"
+relations:
+    - ["wordpress:logs", "logging:logs"]
+`,
+	charms: map[string]charm.Charm{
+		"wordpress": testCharm("wordpress", "logs:logging"),
+		"logging":   testCharm("logging-sub", "| logs:logging"),
+	},
+}, {
+	about: "subordinate charm only related to another subordinate",
+	data: `
+applications:
+    wordpress:
+        charm: "wordpress"
+        num_units: 1
+    logging:
+        charm: "logging"
+    monitoring:
+        charm: "monitoring"
+relations:
+    - ["monitoring:logs", "logging:logs"]
+`,
+	charms: map[string]charm.Charm{
+		"wordpress":  testCharm("wordpress", ""),
+		"logging":    testCharm("logging-sub", "| logs:logging"),
+		"monitoring": testCharm("monitoring-sub", "logs:logging"),
+	},
+	errors: []string{
+		`subordinate application "logging" is not related to any principal application`,
+		`subordinate application "monitoring" is not related to any principal application`,
+	},
+}, {
+	about: "subordinate charm with an invalid relation to a principal",
+	data: `
+applications:
+    wordpress:
+        charm: "wordpress"
+        num_units: 1
+    logging:
+        charm: "logging"
+relations:
+    - ["wordpress:logs", "logging:logs"]
+`,
+	charms: map[string]charm.Charm{
+		"wordpress": testCharm("wordpress", "logs:syslog"),
+		"logging":   testCharm("logging-sub", "| logs:logging"),
+	},
+	errors: []string{
+		`mismatched interface between "wordpress:logs" and "logging:logs" ("syslog" vs "logging")`,
+		`subordinate application "logging" is not related to any principal application`,
+	},
+}, {
+	about: "subordinate charm with unverified relations",
+	data: `
+applications:
+    wordpress:
+        charm: "wordpress"
+        num_units: 1
+    logging:
+        charm: "logging"
+    monitoring:
+        charm: "monitoring"
+relations:
+    - ["wordpress", "logging"]
+    - ["monitoring:logs", "nagios:logs"]
+`,
+	charms: map[string]charm.Charm{
+		"wordpress":  testCharm("wordpress", "logs:syslog"),
+		"logging":    testCharm("logging-sub", "| logs:logging"),
+		"monitoring": testCharm("monitoring-sub", "| logs:logging"),
+	},
+	errors: []string{
+		`cannot infer endpoint between wordpress and logging: no relations found`,
+		`relation ["monitoring:logs" "nagios:logs"] refers to application "nagios" not defined in this bundle`,
 	},
 }, {
 	about: "charm with unspecified units and more than one to: entry",
@@ -1023,7 +1711,7 @@ machines:
     1:
 `,
 	errors: []string{
-		`too many units specified in unit placement for application "test"`,
+		`too many units specified in unit placement for application "test": 2 placement directive(s) for 0 unit(s)`,
 	},
 }}
 
@@ -1116,6 +1804,437 @@ func (*bundleDataSuite) TestParsePlacement(c *gc.C) {
 		} else {
 			c.Assert(err, gc.IsNil)
 			c.Assert(up, jc.DeepEquals, test.expect)
+			c.Assert(up.String(), gc.Equals, test.placement)
+		}
+	}
+}
+
+var newMachineCountTests = []struct {
+	about string
+	data  string
+	count int
+}{{
+	about: "implicit new machines",
+	data: `
+applications:
+    wordpress:
+        charm: wordpress
+        num_units: 3
+    logging:
+        charm: logging
+`,
+	count: 3,
+}, {
+	about: "explicit new placements",
+	data: `
+applications:
+    wordpress:
+        charm: wordpress
+        num_units: 2
+        to: [new, "lxd:new"]
+    mysql:
+        charm: mysql
+        num_units: 2
+        to: [wordpress/0, "lxd:wordpress/1"]
+`,
+	count: 2,
+}, {
+	about: "replicated placements and declared machines",
+	data: `
+applications:
+    wordpress:
+        charm: wordpress
+        num_units: 4
+        to: [0, "kvm:new"]
+    mysql:
+        charm: mysql
+        num_units: 2
+        to: ["lxd:1"]
+machines:
+    0:
+    1:
+`,
+	count: 5,
+}}
+
+func (*bundleDataSuite) TestNewMachineCount(c *gc.C) {
+	for i, test := range newMachineCountTests {
+		c.Logf("test %d: %s", i, test.about)
+		bd, err := charm.ReadBundleData(strings.NewReader(test.data))
+		c.Assert(err, gc.IsNil)
+		c.Assert(bd.NewMachineCount(), gc.Equals, test.count)
+	}
+}
+
+func (*bundleDataSuite) TestVerifyMachineBudget(c *gc.C) {
+	bd, err := charm.ReadBundleData(strings.NewReader(newMachineCountTests[2].data))
+	c.Assert(err, gc.IsNil)
+	err = bd.VerifyMachineBudget(5)
+	c.Assert(err, gc.IsNil)
+	err = bd.VerifyMachineBudget(4)
+	c.Assert(err, gc.FitsTypeOf, (*charm.VerificationError)(nil))
+	c.Assert(err, gc.ErrorMatches, `bundle requires 5 machine\(s\) \(2 declared, 3 implied by placement directives\), exceeding the budget of 4`)
+}
+
+func (*bundleDataSuite) TestEffectiveOptions(c *gc.C) {
+	config := readCharmDir(c, "dummy").Config()
+	spec := &charm.ApplicationSpec{
+		Charm: "cs:dummy",
+		Options: map[string]interface{}{
+			"outlook":     "bright",
+			"skill-level": 42,
+		},
+	}
+	settings, err := charm.EffectiveOptions(config, spec)
+	c.Assert(err, gc.IsNil)
+	c.Assert(settings, jc.DeepEquals, charm.Settings{
+		"title":       "My Title",
+		"outlook":     "bright",
+		"username":    "admin001",
+		"skill-level": int64(42),
+	})
+
+	spec.Options["unknown"] = "value"
+	_, err = charm.EffectiveOptions(config, spec)
+	c.Assert(err, gc.ErrorMatches, `unknown option "unknown"`)
+
+	delete(spec.Options, "unknown")
+	spec.Options["skill-level"] = "high"
+	_, err = charm.EffectiveOptions(config, spec)
+	c.Assert(err, gc.ErrorMatches, `option "skill-level" expected int, got "high"`)
+}
+
+func (*bundleDataSuite) TestVerifyAnnotationKeys(c *gc.C) {
+	bd, err := charm.ReadBundleData(strings.NewReader(`
+applications:
+    wordpress:
+        charm: cs:trusty/wordpress
+        num_units: 1
+        to: [0]
+        annotations:
+            gui-x: 10
+            juju-owner: bob
+    mysql:
+        charm: cs:trusty/mysql
+        num_units: 1
+        annotations:
+            note: database
+machines:
+    0:
+        annotations:
+            juju-zone: east
+annotations:
+    juju-bundle: wordpress
+    description: a blog
+`))
+	c.Assert(err, gc.IsNil)
+
+	// The default reserved prefixes are used when none are given.
+	c.Assert(charm.DefaultReservedAnnotationPrefixes, jc.DeepEquals, []string{"gui-", "juju-"})
+	err = bd.VerifyAnnotationKeys(nil)
+	c.Assert(err, gc.FitsTypeOf, (*charm.VerificationError)(nil))
+	var errStrings []string
+	for _, err := range err.(*charm.VerificationError).Errors {
+		errStrings = append(errStrings, err.Error())
+	}
+	sort.Strings(errStrings)
+	c.Assert(errStrings, jc.DeepEquals, []string{
+		`application "wordpress" declares annotation "gui-x" in reserved namespace "gui-"`,
+		`application "wordpress" declares annotation "juju-owner" in reserved namespace "juju-"`,
+		`bundle declares annotation "juju-bundle" in reserved namespace "juju-"`,
+		`machine "0" declares annotation "juju-zone" in reserved namespace "juju-"`,
+	})
+
+	// Callers can opt out of the check.
+	err = bd.VerifyAnnotationKeys([]string{})
+	c.Assert(err, gc.IsNil)
+	err = bd.VerifyAnnotationKeys([]string{"internal-"})
+	c.Assert(err, gc.IsNil)
+
+	err = bd.VerifyAnnotationKeys([]string{"juju-"})
+	c.Assert(err, gc.FitsTypeOf, (*charm.VerificationError)(nil))
+	errStrings = nil
+	for _, err := range err.(*charm.VerificationError).Errors {
+		errStrings = append(errStrings, err.Error())
+	}
+	sort.Strings(errStrings)
+	c.Assert(errStrings, jc.DeepEquals, []string{
+		`application "wordpress" declares annotation "juju-owner" in reserved namespace "juju-"`,
+		`bundle declares annotation "juju-bundle" in reserved namespace "juju-"`,
+		`machine "0" declares annotation "juju-zone" in reserved namespace "juju-"`,
+	})
+
+	err = bd.VerifyAnnotationKeys([]string{"gui-", "internal-"})
+	c.Assert(err, gc.ErrorMatches, `application "wordpress" declares annotation "gui-x" in reserved namespace "gui-"`)
+}
+
+func (*bundleDataSuite) TestVerifyRelationLimits(c *gc.C) {
+	readMeta := func(data string) charm.Charm {
+		meta, err := charm.ReadMeta(strings.NewReader(data))
+		c.Assert(err, gc.IsNil)
+		return testCharmImpl{meta: meta}
+	}
+	charms := map[string]charm.Charm{
+		"wordpress": readMeta(`
+name: wordpress
+summary: blog
+description: blog
+requires:
+    db: mysql
+    cache:
+        interface: memcache
+        limit: 2
+`),
+		"mysql": readMeta(`
+name: mysql
+summary: database
+description: database
+provides:
+    server: mysql
+`),
+		"memcached": readMeta(`
+name: memcached
+summary: cache
+description: cache
+provides:
+    cache: memcache
+`),
+	}
+	bd, err := charm.ReadBundleData(strings.NewReader(`
+applications:
+    wordpress:
+        charm: wordpress
+        num_units: 1
+    mysql:
+        charm: mysql
+        num_units: 1
+    mysql-slave:
+        charm: mysql
+        num_units: 1
+    memcached:
+        charm: memcached
+        num_units: 1
+    memcached-2:
+        charm: memcached
+        num_units: 1
+relations:
+    - ["wordpress:db", "mysql:server"]
+    - ["wordpress:cache", "memcached:cache"]
+    - ["wordpress", "memcached-2"]
+`))
+	c.Assert(err, gc.IsNil)
+	err = bd.VerifyRelationLimits(charms)
+	c.Assert(err, gc.IsNil)
+
+	bd.Relations = append(bd.Relations, []string{"wordpress", "mysql-slave"})
+	err = bd.VerifyRelationLimits(charms)
+	c.Assert(err, gc.FitsTypeOf, (*charm.VerificationError)(nil))
+	c.Assert(err, gc.ErrorMatches, `relation "db" of application "wordpress" has a limit of 1 but is used by 2 relations`)
+
+	// Duplicate relations are counted once.
+	bd.Relations = append(bd.Relations[:3], []string{"mysql:server", "wordpress:db"})
+	err = bd.VerifyRelationLimits(charms)
+	c.Assert(err, gc.IsNil)
+}
+
+func (*bundleDataSuite) TestVerifyCharmSchema(c *gc.C) {
+	bd, err := charm.ReadBundleData(strings.NewReader(`
+applications:
+    wordpress:
+        charm: local:trusty/wordpress
+        num_units: 1
+    mysql:
+        charm: ./charms/mysql
+        num_units: 1
+    haproxy:
+        charm: cs:trusty/haproxy
+        num_units: 1
+    memcached:
+        charm: memcached
+        num_units: 1
+`))
+	c.Assert(err, gc.IsNil)
+
+	err = bd.VerifyCharmSchema("local")
+	c.Assert(err, gc.FitsTypeOf, (*charm.VerificationError)(nil))
+	var errStrings []string
+	for _, err := range err.(*charm.VerificationError).Errors {
+		errStrings = append(errStrings, err.Error())
+	}
+	sort.Strings(errStrings)
+	c.Assert(errStrings, jc.DeepEquals, []string{
+		`charm "cs:trusty/haproxy" used by application "haproxy" has schema "cs", expected "local"`,
+		`charm "memcached" used by application "memcached" has schema "cs", expected "local"`,
+	})
+
+	delete(bd.Applications, "haproxy")
+	delete(bd.Applications, "memcached")
+	err = bd.VerifyCharmSchema("local")
+	c.Assert(err, gc.IsNil)
+	err = bd.VerifyCharmSchema("cs")
+	c.Assert(err, gc.FitsTypeOf, (*charm.VerificationError)(nil))
+	c.Assert(err.(*charm.VerificationError).Errors, gc.HasLen, 2)
+}
+
+var bundleArchs = map[string][]string{
+	"cs:trusty/wordpress": {"amd64", "arm64"},
+	"cs:trusty/mysql":     {"amd64"},
+	"cs:trusty/armdb":     {"arm64"},
+	"cs:trusty/ppcdb":     {"ppc64el"},
+	"cs:trusty/anyarch":   {},
+}
+
+var verifyArchitecturesTests = []struct {
+	about        string
+	data         string
+	expectErrors []string
+}{{
+	about: "compatible applications on the same machine",
+	data: `
+applications:
+    wordpress:
+        charm: cs:trusty/wordpress
+        num_units: 1
+        to: [0]
+    mysql:
+        charm: cs:trusty/mysql
+        num_units: 1
+        to: ["lxc:0"]
+machines:
+    0:
+`,
+}, {
+	about: "incompatible applications on separate machines",
+	data: `
+applications:
+    mysql:
+        charm: cs:trusty/mysql
+        num_units: 2
+    armdb:
+        charm: cs:trusty/armdb
+        num_units: 1
+        to: [0]
+machines:
+    0:
+`,
+}, {
+	about: "incompatible applications on the same machine",
+	data: `
+applications:
+    mysql:
+        charm: cs:trusty/mysql
+        num_units: 1
+        to: [0]
+    armdb:
+        charm: cs:trusty/armdb
+        num_units: 1
+        to: ["kvm:0"]
+machines:
+    0:
+`,
+	expectErrors: []string{
+		`applications "armdb", "mysql" placed on machine "0" have no architecture in common`,
+	},
+}, {
+	about: "incompatible applications co-located with a unit",
+	data: `
+applications:
+    wordpress:
+        charm: cs:trusty/wordpress
+        num_units: 2
+    mysql:
+        charm: cs:trusty/mysql
+        num_units: 1
+        to: [wordpress/1]
+    armdb:
+        charm: cs:trusty/armdb
+        num_units: 2
+        to: ["lxc:wordpress"]
+`,
+	expectErrors: []string{
+		`applications "armdb", "mysql", "wordpress" placed on the new machine of unit "wordpress/1" have no architecture in common`,
+	},
+}, {
+	about: "no architecture common to three applications",
+	data: `
+applications:
+    wordpress:
+        charm: cs:trusty/wordpress
+        num_units: 1
+        to: [0]
+    armdb:
+        charm: cs:trusty/armdb
+        num_units: 1
+        to: ["lxc:0"]
+    mysql:
+        charm: cs:trusty/mysql
+        num_units: 1
+        to: ["lxc:0"]
+    ppcdb:
+        charm: cs:trusty/ppcdb
+        num_units: 1
+        to: [1]
+machines:
+    0:
+    1:
+`,
+	expectErrors: []string{
+		`applications "armdb", "mysql", "wordpress" placed on machine "0" have no architecture in common`,
+	},
+}, {
+	about: "charms without architecture information",
+	data: `
+applications:
+    mysql:
+        charm: cs:trusty/mysql
+        num_units: 1
+        to: [0]
+    anyarch:
+        charm: cs:trusty/anyarch
+        num_units: 1
+        to: [0]
+    unknown:
+        charm: cs:trusty/unknown
+        num_units: 1
+        to: [0]
+machines:
+    0:
+`,
+}, {
+	about: "placement cycles and undefined units are ignored",
+	data: `
+applications:
+    mysql:
+        charm: cs:trusty/mysql
+        num_units: 1
+        to: [armdb/0]
+    armdb:
+        charm: cs:trusty/armdb
+        num_units: 1
+        to: [mysql/0]
+    ppcdb:
+        charm: cs:trusty/ppcdb
+        num_units: 1
+        to: [mysql/5]
+`,
+}}
+
+func (*bundleDataSuite) TestVerifyArchitectures(c *gc.C) {
+	for i, test := range verifyArchitecturesTests {
+		c.Logf("test %d: %s", i, test.about)
+		bd, err := charm.ReadBundleData(strings.NewReader(test.data))
+		c.Assert(err, gc.IsNil)
+		err = bd.VerifyArchitectures(bundleArchs)
+		if len(test.expectErrors) == 0 {
+			c.Assert(err, gc.IsNil)
+			continue
+		}
+		c.Assert(err, gc.FitsTypeOf, (*charm.VerificationError)(nil))
+		var errStrings []string
+		for _, err := range err.(*charm.VerificationError).Errors {
+			errStrings = append(errStrings, err.Error())
 		}
+		sort.Strings(errStrings)
+		c.Assert(errStrings, jc.DeepEquals, test.expectErrors)
 	}
 }
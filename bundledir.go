@@ -33,7 +33,16 @@ func ReadBundleDir(path string) (dir *BundleDir, err error) {
 	if err != nil {
 		return nil, err
 	}
-	readMe, err := ioutil.ReadFile(dir.join("README.md"))
+	names, err := readDirNames(dir.Path)
+	if err != nil {
+		return nil, err
+	}
+	readMeName := findReadMe(names)
+	if readMeName == "" {
+		// Report the error for the canonical file name.
+		readMeName = "README.md"
+	}
+	readMe, err := ioutil.ReadFile(dir.join(readMeName))
 	if err != nil {
 		return nil, fmt.Errorf("cannot read README file: %v", err)
 	}
@@ -53,6 +62,16 @@ func (dir *BundleDir) ArchiveTo(w io.Writer) error {
 	return writeArchive(w, dir.Path, -1, nil)
 }
 
+// readDirNames returns the names of the files in the given directory.
+func readDirNames(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Readdirnames(-1)
+}
+
 // join builds a path rooted at the bundle's expanded directory
 // path and the extra path components provided.
 func (dir *BundleDir) join(parts ...string) string {
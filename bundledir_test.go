@@ -4,6 +4,7 @@
 package charm_test
 
 import (
+	"io/ioutil"
 	"os"
 	"path/filepath"
 
@@ -35,6 +36,53 @@ func (s *BundleDirSuite) TestReadBundleDirWithoutREADME(c *gc.C) {
 	c.Assert(dir, gc.IsNil)
 }
 
+var readMeVariantsTests = []struct {
+	about  string
+	files  map[string]string
+	readMe string
+}{{
+	about:  "README.txt",
+	files:  map[string]string{"README.txt": "text readme"},
+	readMe: "text readme",
+}, {
+	about:  "lower case readme.md",
+	files:  map[string]string{"readme.md": "lower case readme"},
+	readMe: "lower case readme",
+}, {
+	about:  "README without extension",
+	files:  map[string]string{"README": "plain readme"},
+	readMe: "plain readme",
+}, {
+	about: "README.md is preferred",
+	files: map[string]string{
+		"README":     "plain readme",
+		"readme.txt": "text readme",
+		"readme.md":  "lower case readme",
+		"README.md":  "markdown readme",
+	},
+	readMe: "markdown readme",
+}}
+
+func (s *BundleDirSuite) TestReadBundleDirWithREADMEVariants(c *gc.C) {
+	for i, test := range readMeVariantsTests {
+		c.Logf("test %d: %s", i, test.about)
+		path := cloneDir(c, bundleDirPath(c, "wordpress-simple"))
+		err := os.Remove(filepath.Join(path, "README.md"))
+		c.Assert(err, gc.IsNil)
+		for name, content := range test.files {
+			err := ioutil.WriteFile(filepath.Join(path, name), []byte(content), 0644)
+			c.Assert(err, gc.IsNil)
+		}
+		dir, err := charm.ReadBundleDir(path)
+		c.Assert(err, gc.IsNil)
+		c.Assert(dir.ReadMe(), gc.Equals, test.readMe)
+
+		archive, err := charm.ReadBundleArchive(archivePath(c, dir))
+		c.Assert(err, gc.IsNil)
+		c.Assert(archive.ReadMe(), gc.Equals, test.readMe)
+	}
+}
+
 func (s *BundleDirSuite) TestArchiveTo(c *gc.C) {
 	baseDir := c.MkDir()
 	charmDir := cloneDir(c, bundleDirPath(c, "wordpress-simple"))
@@ -0,0 +1,138 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+// BundleFormat identifies the format of a bundle file.
+type BundleFormat int
+
+const (
+	// BundleFormatV3 identifies the legacy juju-deployer format,
+	// where the file holds one or more named bundles, each one
+	// with its own services, relations and series. The deployer
+	// formats that preceded the current bundle format are
+	// historically referred to collectively as v3, the last of
+	// them, so this is the name used here for any legacy bundle.
+	BundleFormatV3 BundleFormat = 3
+
+	// BundleFormatV4 identifies the format read by ReadBundleData,
+	// where the file holds a single bundle.
+	BundleFormatV4 BundleFormat = 4
+)
+
+// bundleV4Keys holds the top level keys of a bundle in the v4 format.
+var bundleV4Keys = map[string]bool{
+	"applications": true,
+	"services":     true,
+	"machines":     true,
+	"series":       true,
+	"relations":    true,
+	"tags":         true,
+	"description":  true,
+	"docs":         true,
+	"annotations":  true,
+}
+
+// ReadBundleFormat reads a bundle file in YAML format and returns its
+// format, detected from the structure of the file. A v3 bundle file
+// holds named bundles defining their services, while a v4 bundle file
+// holds the applications, machines and relations of a single bundle.
+// A v4 bundle must also be readable by ReadBundleData.
+//
+// If the bundle mixes v3 and v4 constructs, ReadBundleFormat returns
+// a *VerificationError describing all the problems found.
+func ReadBundleFormat(r io.Reader) (BundleFormat, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	var top map[string]interface{}
+	if err := yaml.Unmarshal(data, &top); err != nil {
+		return 0, fmt.Errorf("cannot unmarshal bundle data: %v", err)
+	}
+	var v4Keys, v3Bundles []string
+	for key, value := range top {
+		if bundleV4Keys[key] {
+			v4Keys = append(v4Keys, key)
+		} else if isLegacyBundle(value) {
+			v3Bundles = append(v3Bundles, key)
+		}
+	}
+	sort.Strings(v4Keys)
+	sort.Strings(v3Bundles)
+	if len(v4Keys) == 0 && len(v3Bundles) == 0 {
+		return 0, fmt.Errorf("cannot determine bundle format")
+	}
+	verifier := &bundleDataVerifier{}
+	if len(v4Keys) == 0 {
+		for _, name := range v3Bundles {
+			verifyLegacyBundle(verifier, name, top[name].(map[interface{}]interface{}))
+		}
+		if err := verifier.err(); err != nil {
+			return 0, err
+		}
+		return BundleFormatV3, nil
+	}
+	for _, name := range v3Bundles {
+		verifier.addErrorf("bundle declares v4 keys %s but also v3 bundle %q", quoteNames(v4Keys), name)
+	}
+	for _, key := range []string{"applications", "services"} {
+		apps, _ := top[key].(map[interface{}]interface{})
+		for name, app := range apps {
+			app, _ := app.(map[interface{}]interface{})
+			if _, ok := app["branch"]; ok {
+				verifier.addErrorf("application %q uses the v3 %q field", name, "branch")
+			}
+			if to, ok := app["to"].(string); ok {
+				verifier.addErrorf("application %q specifies v3 placement %q rather than a list", name, to)
+			}
+		}
+	}
+	if err := verifier.err(); err != nil {
+		return 0, err
+	}
+	if _, err := ReadBundleData(bytes.NewReader(data)); err != nil {
+		return 0, fmt.Errorf("invalid v4 bundle: %v", err)
+	}
+	return BundleFormatV4, nil
+}
+
+// bundleV4OnlyKeys holds the keys that are only valid at the
+// top level of a v4 bundle, and so should not be found in a
+// named v3 bundle.
+var bundleV4OnlyKeys = []string{"applications", "machines"}
+
+// verifyLegacyBundle checks that the named v3 bundle does not use
+// keys that are only valid in v4 bundles. Service fields are not
+// checked, as the deployer accepted most of them in both formats,
+// including placements given as a list.
+func verifyLegacyBundle(verifier *bundleDataVerifier, name string, bundle map[interface{}]interface{}) {
+	for _, key := range bundleV4OnlyKeys {
+		if _, ok := bundle[key]; ok {
+			verifier.addErrorf("v3 bundle %q uses the v4 %q key", name, key)
+		}
+	}
+}
+
+// isLegacyBundle reports whether the given value,
+// found at the top level of a bundle file, holds
+// a bundle in the v3 format.
+func isLegacyBundle(value interface{}) bool {
+	m, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return false
+	}
+	_, hasServices := m["services"]
+	_, hasInherits := m["inherits"]
+	return hasServices || hasInherits
+}
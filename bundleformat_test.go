@@ -0,0 +1,148 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm_test
+
+import (
+	"sort"
+	"strings"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"gopkg.in/juju/charm.v6-unstable"
+)
+
+type bundleFormatSuite struct{}
+
+var _ = gc.Suite(&bundleFormatSuite{})
+
+var readBundleFormatTests = []struct {
+	about        string
+	data         string
+	format       charm.BundleFormat
+	expectErrors []string
+}{{
+	about: "v3 bundle",
+	data: `
+wordpress-simple:
+    series: trusty
+    services:
+        wordpress:
+            charm: cs:trusty/wordpress
+            num_units: 1
+        mysql:
+            charm: cs:trusty/mysql
+            to: "wordpress=0"
+    relations:
+        - [wordpress, mysql]
+wordpress-scaled:
+    inherits: wordpress-simple
+`,
+	format: charm.BundleFormatV3,
+}, {
+	about: "v3 bundle using v4 constructs",
+	data: `
+wordpress-simple:
+    series: trusty
+    services:
+        wordpress:
+            charm: cs:trusty/wordpress
+            num_units: 1
+            to: [0]
+    applications:
+        mysql:
+            charm: cs:trusty/mysql
+            to: "wordpress=0"
+    machines:
+        0:
+`,
+	expectErrors: []string{
+		`v3 bundle "wordpress-simple" uses the v4 "applications" key`,
+		`v3 bundle "wordpress-simple" uses the v4 "machines" key`,
+	},
+}, {
+	about: "v3 bundle with list placement",
+	data: `
+wordpress-simple:
+    services:
+        wordpress:
+            charm: cs:trusty/wordpress
+            num_units: 2
+            to: ["lxc:0", "mysql=0"]
+        mysql:
+            charm: cs:trusty/mysql
+`,
+	format: charm.BundleFormatV3,
+}, {
+	about: "v4 bundle",
+	data: `
+series: trusty
+applications:
+    wordpress:
+        charm: cs:trusty/wordpress
+        num_units: 1
+        to: [0]
+    mysql:
+        charm: cs:trusty/mysql
+        num_units: 1
+machines:
+    0:
+relations:
+    - ["wordpress:db", "mysql:server"]
+`,
+	format: charm.BundleFormatV4,
+}, {
+	about:  "v4 bundle using services",
+	data:   "services: {wordpress: {charm: wordpress}}",
+	format: charm.BundleFormatV4,
+}, {
+	about: "mixed v3 and v4 constructs",
+	data: `
+applications:
+    wordpress:
+        charm: cs:trusty/wordpress
+        branch: lp:charms/wordpress
+        num_units: 1
+    mysql:
+        charm: cs:trusty/mysql
+        to: "wordpress=0"
+legacy:
+    services:
+        haproxy:
+            charm: cs:trusty/haproxy
+`,
+	expectErrors: []string{
+		`application "mysql" specifies v3 placement "wordpress=0" rather than a list`,
+		`application "wordpress" uses the v3 "branch" field`,
+		`bundle declares v4 keys "applications" but also v3 bundle "legacy"`,
+	},
+}}
+
+func (*bundleFormatSuite) TestReadBundleFormat(c *gc.C) {
+	for i, test := range readBundleFormatTests {
+		c.Logf("test %d: %s", i, test.about)
+		format, err := charm.ReadBundleFormat(strings.NewReader(test.data))
+		if len(test.expectErrors) == 0 {
+			c.Assert(err, gc.IsNil)
+			c.Assert(format, gc.Equals, test.format)
+			continue
+		}
+		c.Assert(err, gc.FitsTypeOf, (*charm.VerificationError)(nil))
+		var errStrings []string
+		for _, err := range err.(*charm.VerificationError).Errors {
+			errStrings = append(errStrings, err.Error())
+		}
+		sort.Strings(errStrings)
+		c.Assert(errStrings, jc.DeepEquals, test.expectErrors)
+	}
+}
+
+func (*bundleFormatSuite) TestReadBundleFormatErrors(c *gc.C) {
+	_, err := charm.ReadBundleFormat(strings.NewReader("foo: bar"))
+	c.Assert(err, gc.ErrorMatches, "cannot determine bundle format")
+	_, err = charm.ReadBundleFormat(strings.NewReader("applications: {"))
+	c.Assert(err, gc.ErrorMatches, "cannot unmarshal bundle data: yaml: .*")
+	_, err = charm.ReadBundleFormat(strings.NewReader("applications: {wordpress: {charm: wordpress, num_units: many}}"))
+	c.Assert(err, gc.ErrorMatches, `invalid v4 bundle: cannot unmarshal bundle data: yaml: (.|\n)*cannot unmarshal !!str .many. into int`)
+}
@@ -0,0 +1,120 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Hash returns a hex-encoded SHA256 hash of the semantic content of
+// the bundle. Bundles that differ only in the order of their keys,
+// relations or relation endpoints, or in their use of the placement
+// shorthand described in ApplicationSpec.To, have the same hash.
+//
+// The bundle data is not verified, and bundles with invalid
+// placement directives can still be hashed.
+func (bd *BundleData) Hash() (string, error) {
+	data, err := yaml.Marshal(bd.normalized())
+	if err != nil {
+		return "", fmt.Errorf("cannot marshal bundle data: %v", err)
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+// normalized returns a copy of the bundle data in a canonical form,
+// suitable for comparing bundles. The receiver is not modified.
+func (bd *BundleData) normalized() *BundleData {
+	nbd := *bd
+	nbd.unmarshaledWithServices = false
+	if bd.Applications != nil {
+		nbd.Applications = make(map[string]*ApplicationSpec, len(bd.Applications))
+		for name, app := range bd.Applications {
+			if app == nil {
+				nbd.Applications[name] = nil
+				continue
+			}
+			napp := *app
+			napp.To = normalizedPlacements(app.To, app.NumUnits)
+			nbd.Applications[name] = &napp
+		}
+	}
+	nbd.Relations = normalizedRelations(bd.Relations)
+	return &nbd
+}
+
+// normalizedPlacements returns the given unit placement directives
+// with one entry for each unit, expanding the last directive as
+// required and filling in missing unit numbers.
+// Directives that cannot be parsed are left unchanged.
+func normalizedPlacements(to []string, numUnits int) []string {
+	n := numUnits
+	if len(to) > n {
+		n = len(to)
+	}
+	if n == 0 {
+		return nil
+	}
+	// nextUnit holds, for each application, the unit number
+	// that will be used by the next placement which
+	// does not specify one.
+	nextUnit := make(map[string]int)
+	placements := make([]string, n)
+	for i := range placements {
+		p := "new"
+		if len(to) > 0 {
+			if i < len(to) {
+				p = to[i]
+			} else {
+				p = to[len(to)-1]
+			}
+		}
+		up, err := ParsePlacement(p)
+		if err != nil {
+			placements[i] = p
+			continue
+		}
+		if up.Application != "" {
+			if up.Unit == -1 {
+				up.Unit = nextUnit[up.Application]
+			}
+			nextUnit[up.Application] = up.Unit + 1
+		}
+		placements[i] = up.String()
+	}
+	return placements
+}
+
+// normalizedRelations returns a sorted copy of the given relations,
+// with the endpoints of each relation sorted too.
+func normalizedRelations(relations [][]string) [][]string {
+	if relations == nil {
+		return nil
+	}
+	nrelations := make([][]string, len(relations))
+	for i, rel := range relations {
+		nrel := append([]string(nil), rel...)
+		sort.Strings(nrel)
+		nrelations[i] = nrel
+	}
+	sort.Sort(relationsByEndpoints(nrelations))
+	return nrelations
+}
+
+type relationsByEndpoints [][]string
+
+func (r relationsByEndpoints) Len() int      { return len(r) }
+func (r relationsByEndpoints) Swap(i, j int) { r[i], r[j] = r[j], r[i] }
+func (r relationsByEndpoints) Less(i, j int) bool {
+	ri, rj := r[i], r[j]
+	for k := 0; k < len(ri) && k < len(rj); k++ {
+		if ri[k] != rj[k] {
+			return ri[k] < rj[k]
+		}
+	}
+	return len(ri) < len(rj)
+}
@@ -0,0 +1,241 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm_test
+
+import (
+	"strings"
+
+	gc "gopkg.in/check.v1"
+
+	"gopkg.in/juju/charm.v6-unstable"
+)
+
+type bundleHashSuite struct{}
+
+var _ = gc.Suite(&bundleHashSuite{})
+
+const hashBundle = `
+series: trusty
+applications:
+    wordpress:
+        charm: cs:trusty/wordpress-42
+        num_units: 3
+        to: [0, "lxc:0", new]
+        options:
+            blog-title: my blog
+            debug: true
+    mysql:
+        charm: cs:trusty/mysql-27
+        num_units: 2
+        to: [wordpress, wordpress]
+machines:
+    0:
+        constraints: mem=2G
+relations:
+    - ["wordpress:db", "mysql:db"]
+    - ["wordpress:cache", "memcached:cache"]
+`
+
+var equivalentHashBundleTests = []struct {
+	about string
+	data  string
+}{{
+	about: "same bundle",
+	data:  hashBundle,
+}, {
+	about: "different key ordering",
+	data: `
+relations:
+    - ["wordpress:db", "mysql:db"]
+    - ["wordpress:cache", "memcached:cache"]
+machines:
+    0:
+        constraints: mem=2G
+applications:
+    mysql:
+        to: [wordpress, wordpress]
+        num_units: 2
+        charm: cs:trusty/mysql-27
+    wordpress:
+        options:
+            debug: true
+            blog-title: my blog
+        to: [0, "lxc:0", new]
+        num_units: 3
+        charm: cs:trusty/wordpress-42
+series: trusty
+`,
+}, {
+	about: "different relation ordering",
+	data: `
+series: trusty
+applications:
+    wordpress:
+        charm: cs:trusty/wordpress-42
+        num_units: 3
+        to: [0, "lxc:0", new]
+        options:
+            blog-title: my blog
+            debug: true
+    mysql:
+        charm: cs:trusty/mysql-27
+        num_units: 2
+        to: [wordpress, wordpress]
+machines:
+    0:
+        constraints: mem=2G
+relations:
+    - ["memcached:cache", "wordpress:cache"]
+    - ["mysql:db", "wordpress:db"]
+`,
+}, {
+	about: "placement shorthand",
+	data: `
+series: trusty
+applications:
+    wordpress:
+        charm: cs:trusty/wordpress-42
+        num_units: 3
+        to: [0, "lxc:0", new]
+        options:
+            blog-title: my blog
+            debug: true
+    mysql:
+        charm: cs:trusty/mysql-27
+        num_units: 2
+        to: [wordpress/0, wordpress/1]
+machines:
+    0:
+        constraints: mem=2G
+relations:
+    - ["wordpress:db", "mysql:db"]
+    - ["wordpress:cache", "memcached:cache"]
+`,
+}, {
+	about: "placement shorthand with replicated application placement",
+	data: `
+series: trusty
+applications:
+    wordpress:
+        charm: cs:trusty/wordpress-42
+        num_units: 3
+        to: [0, "lxc:0", new]
+        options:
+            blog-title: my blog
+            debug: true
+    mysql:
+        charm: cs:trusty/mysql-27
+        num_units: 2
+        to: [wordpress]
+machines:
+    0:
+        constraints: mem=2G
+relations:
+    - ["wordpress:db", "mysql:db"]
+    - ["wordpress:cache", "memcached:cache"]
+`,
+}, {
+	about: "legacy services",
+	data: `
+series: trusty
+services:
+    wordpress:
+        charm: cs:trusty/wordpress-42
+        num_units: 3
+        to: [0, "lxc:0", new]
+        options:
+            blog-title: my blog
+            debug: true
+    mysql:
+        charm: cs:trusty/mysql-27
+        num_units: 2
+        to: [wordpress, wordpress]
+machines:
+    0:
+        constraints: mem=2G
+relations:
+    - ["wordpress:db", "mysql:db"]
+    - ["wordpress:cache", "memcached:cache"]
+`,
+}}
+
+func (*bundleHashSuite) TestHashEquivalentBundles(c *gc.C) {
+	expectHash := bundleHash(c, hashBundle)
+	for i, test := range equivalentHashBundleTests {
+		c.Logf("test %d: %s", i, test.about)
+		c.Assert(bundleHash(c, test.data), gc.Equals, expectHash)
+	}
+}
+
+var differentHashBundleTests = []struct {
+	about  string
+	mutate func(bd *charm.BundleData)
+}{{
+	about: "different charm",
+	mutate: func(bd *charm.BundleData) {
+		bd.Applications["mysql"].Charm = "cs:trusty/mysql-28"
+	},
+}, {
+	about: "different number of units",
+	mutate: func(bd *charm.BundleData) {
+		bd.Applications["wordpress"].NumUnits = 4
+	},
+}, {
+	about: "different placement",
+	mutate: func(bd *charm.BundleData) {
+		bd.Applications["mysql"].To = []string{"wordpress/0", "wordpress/2"}
+	},
+}, {
+	about: "different option",
+	mutate: func(bd *charm.BundleData) {
+		bd.Applications["wordpress"].Options["debug"] = false
+	},
+}, {
+	about: "different relation",
+	mutate: func(bd *charm.BundleData) {
+		bd.Relations = bd.Relations[:1]
+	},
+}, {
+	about: "different machine",
+	mutate: func(bd *charm.BundleData) {
+		bd.Machines["0"].Constraints = "mem=4G"
+	},
+}, {
+	about: "different series",
+	mutate: func(bd *charm.BundleData) {
+		bd.Series = "xenial"
+	},
+}}
+
+func (*bundleHashSuite) TestHashDifferentBundles(c *gc.C) {
+	origHash := bundleHash(c, hashBundle)
+	for i, test := range differentHashBundleTests {
+		c.Logf("test %d: %s", i, test.about)
+		bd, err := charm.ReadBundleData(strings.NewReader(hashBundle))
+		c.Assert(err, gc.IsNil)
+		test.mutate(bd)
+		hash, err := bd.Hash()
+		c.Assert(err, gc.IsNil)
+		c.Assert(hash, gc.Not(gc.Equals), origHash)
+	}
+}
+
+func (*bundleHashSuite) TestHashDoesNotModifyBundle(c *gc.C) {
+	bd, err := charm.ReadBundleData(strings.NewReader(hashBundle))
+	c.Assert(err, gc.IsNil)
+	_, err = bd.Hash()
+	c.Assert(err, gc.IsNil)
+	c.Assert(bd.Applications["mysql"].To, gc.DeepEquals, []string{"wordpress", "wordpress"})
+	c.Assert(bd.Relations[0], gc.DeepEquals, []string{"wordpress:db", "mysql:db"})
+	c.Assert(bd.UnmarshaledWithServices(), gc.Equals, false)
+}
+
+func bundleHash(c *gc.C, data string) string {
+	bd, err := charm.ReadBundleData(strings.NewReader(data))
+	c.Assert(err, gc.IsNil)
+	hash, err := bd.Hash()
+	c.Assert(err, gc.IsNil)
+	c.Assert(hash, gc.HasLen, 64)
+	return hash
+}
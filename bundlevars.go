@@ -0,0 +1,86 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// bundleVariable matches a ${NAME} placeholder in a bundle value.
+var bundleVariable = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// SubstituteVariables replaces the ${NAME} placeholders found in the
+// given bundle with the corresponding values in vars. Placeholders
+// are substituted in the string values of application options, in
+// application and machine constraints and in bundle, application and
+// machine annotations.
+//
+// Only option values that are strings in the bundle are considered,
+// and the substituted value is always a string. For an option of
+// another type, such as "port: ${PORT}" for an int option, the
+// string is only converted to the option type when the bundle is
+// verified against the charm config, which fails if the substituted
+// value is not valid for that type.
+//
+// If any placeholder has no corresponding entry in vars,
+// SubstituteVariables returns an error listing all the unresolved
+// variables and the bundle is not modified.
+func SubstituteVariables(bd *BundleData, vars map[string]string) error {
+	unresolved := make(map[string]bool)
+	walkBundleStrings(bd, func(s string) string {
+		for _, m := range bundleVariable.FindAllStringSubmatch(s, -1) {
+			if _, ok := vars[m[1]]; !ok {
+				unresolved[m[1]] = true
+			}
+		}
+		return s
+	})
+	if len(unresolved) > 0 {
+		names := make([]string, 0, len(unresolved))
+		for name := range unresolved {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unresolved bundle variables: %s", strings.Join(names, ", "))
+	}
+	walkBundleStrings(bd, func(s string) string {
+		return bundleVariable.ReplaceAllStringFunc(s, func(v string) string {
+			return vars[v[2:len(v)-1]]
+		})
+	})
+	return nil
+}
+
+// walkBundleStrings calls f on each bundle value that may hold
+// variable placeholders, replacing the value with the result.
+func walkBundleStrings(bd *BundleData, f func(string) string) {
+	walkAnnotations := func(annotations map[string]string) {
+		for key, value := range annotations {
+			annotations[key] = f(value)
+		}
+	}
+	walkAnnotations(bd.Annotations)
+	for _, app := range bd.Applications {
+		if app == nil {
+			continue
+		}
+		for key, value := range app.Options {
+			if s, ok := value.(string); ok {
+				app.Options[key] = f(s)
+			}
+		}
+		app.Constraints = f(app.Constraints)
+		walkAnnotations(app.Annotations)
+	}
+	for _, machine := range bd.Machines {
+		if machine == nil {
+			continue
+		}
+		machine.Constraints = f(machine.Constraints)
+		walkAnnotations(machine.Annotations)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2016 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm_test
+
+import (
+	"strings"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"gopkg.in/juju/charm.v6-unstable"
+)
+
+type bundleVarsSuite struct{}
+
+var _ = gc.Suite(&bundleVarsSuite{})
+
+const variablesBundle = `
+applications:
+    wordpress:
+        charm: cs:trusty/wordpress
+        num_units: 1
+        constraints: mem=${WP_MEM} cpu-cores=2
+        options:
+            blog-title: ${TITLE} by ${AUTHOR}
+            port: 8080
+        annotations:
+            gui-x: ${X}
+        to: [0]
+machines:
+    0:
+        constraints: arch=${ARCH}
+annotations:
+    owner: ${AUTHOR}
+`
+
+func (*bundleVarsSuite) TestSubstituteVariables(c *gc.C) {
+	bd, err := charm.ReadBundleData(strings.NewReader(variablesBundle))
+	c.Assert(err, gc.IsNil)
+	err = charm.SubstituteVariables(bd, map[string]string{
+		"WP_MEM": "4G",
+		"TITLE":  "My Blog",
+		"AUTHOR": "bob",
+		"X":      "100",
+		"ARCH":   "amd64",
+		"UNUSED": "42",
+	})
+	c.Assert(err, gc.IsNil)
+	app := bd.Applications["wordpress"]
+	c.Assert(app.Constraints, gc.Equals, "mem=4G cpu-cores=2")
+	c.Assert(app.Options, jc.DeepEquals, map[string]interface{}{
+		"blog-title": "My Blog by bob",
+		"port":       8080,
+	})
+	c.Assert(app.Annotations, jc.DeepEquals, map[string]string{"gui-x": "100"})
+	c.Assert(bd.Machines["0"].Constraints, gc.Equals, "arch=amd64")
+	c.Assert(bd.Annotations, jc.DeepEquals, map[string]string{"owner": "bob"})
+}
+
+func (*bundleVarsSuite) TestSubstituteVariablesUnresolved(c *gc.C) {
+	bd, err := charm.ReadBundleData(strings.NewReader(variablesBundle))
+	c.Assert(err, gc.IsNil)
+	err = charm.SubstituteVariables(bd, map[string]string{
+		"WP_MEM": "4G",
+		"TITLE":  "My Blog",
+		"X":      "100",
+	})
+	c.Assert(err, gc.ErrorMatches, `unresolved bundle variables: ARCH, AUTHOR`)
+	// The bundle is left unchanged.
+	c.Assert(bd.Applications["wordpress"].Constraints, gc.Equals, "mem=${WP_MEM} cpu-cores=2")
+}
+
+func (*bundleVarsSuite) TestSubstituteVariablesNonStringOption(c *gc.C) {
+	charms := map[string]charm.Charm{
+		"cs:trusty/wordpress": testCharm("wordpress", ""),
+	}
+	for i, test := range []struct {
+		level       string
+		expectError string
+	}{{
+		level: "3",
+	}, {
+		level:       "high",
+		expectError: `cannot validate application "wordpress": option "skill-level" expected int, got "high"`,
+	}} {
+		c.Logf("test %d: %s", i, test.level)
+		bd, err := charm.ReadBundleData(strings.NewReader(`
+applications:
+    wordpress:
+        charm: cs:trusty/wordpress
+        num_units: 1
+        options:
+            skill-level: ${LEVEL}
+`))
+		c.Assert(err, gc.IsNil)
+		err = charm.SubstituteVariables(bd, map[string]string{"LEVEL": test.level})
+		c.Assert(err, gc.IsNil)
+		// The substituted value is always a string.
+		c.Assert(bd.Applications["wordpress"].Options["skill-level"], gc.Equals, test.level)
+		err = bd.VerifyWithCharms(nil, nil, charms)
+		if test.expectError == "" {
+			c.Assert(err, gc.IsNil)
+		} else {
+			c.Assert(err, gc.ErrorMatches, test.expectError)
+		}
+	}
+}
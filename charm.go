@@ -62,7 +62,37 @@ func SeriesForCharm(requestedSeries string, supportedSeries []string) (string, e
 			return requestedSeries, nil
 		}
 	}
-	return "", &unsupportedSeriesError{requestedSeries, supportedSeries}
+	return "", &unsupportedSeriesError{requestedSeries: requestedSeries, supportedSeries: supportedSeries}
+}
+
+// PreferredSeries returns the first series in preferredSeries that is
+// supported by a charm with the given supported series. This can be
+// used to choose a deterministic series, for instance the newest LTS,
+// when none has been requested for a multi-series charm.
+//
+// Old charms that do not declare any supported series are assumed
+// to support the first preferred series. An error satisfying
+// IsUnsupportedSeriesError is returned if none of the preferred
+// series is supported by the charm.
+func PreferredSeries(supportedSeries, preferredSeries []string) (string, error) {
+	if len(supportedSeries) == 0 {
+		if len(preferredSeries) == 0 {
+			return "", missingSeriesError
+		}
+		return preferredSeries[0], nil
+	}
+	for _, preferred := range preferredSeries {
+		for _, s := range supportedSeries {
+			if s == preferred {
+				return preferred, nil
+			}
+		}
+	}
+	return "", &unsupportedSeriesError{
+		supportedSeries: supportedSeries,
+		preferredSeries: preferredSeries,
+		preferred:       true,
+	}
 }
 
 // missingSeriesError is used to denote that SeriesForCharm could not determine
@@ -79,9 +109,20 @@ func IsMissingSeriesError(err error) bool {
 type unsupportedSeriesError struct {
 	requestedSeries string
 	supportedSeries []string
+
+	// preferredSeries holds the series tried by PreferredSeries,
+	// in which case preferred is true.
+	preferredSeries []string
+	preferred       bool
 }
 
 func (e *unsupportedSeriesError) Error() string {
+	if e.preferred {
+		return fmt.Sprintf(
+			"none of the preferred series %v supported by charm, supported series are: %s",
+			e.preferredSeries, strings.Join(e.supportedSeries, ","),
+		)
+	}
 	return fmt.Sprintf(
 		"series %q not supported by charm, supported series are: %s",
 		e.requestedSeries, strings.Join(e.supportedSeries, ","),
@@ -91,7 +132,7 @@ func (e *unsupportedSeriesError) Error() string {
 // NewUnsupportedSeriesError returns an error indicating that the requested series
 // is not supported by a charm.
 func NewUnsupportedSeriesError(requestedSeries string, supportedSeries []string) error {
-	return &unsupportedSeriesError{requestedSeries, supportedSeries}
+	return &unsupportedSeriesError{requestedSeries: requestedSeries, supportedSeries: supportedSeries}
 }
 
 // IsUnsupportedSeriesError returns true if err is an UnsupportedSeriesError.
@@ -90,6 +90,55 @@ func (s *CharmSuite) TestSeriesToUse(c *gc.C) {
 	}
 }
 
+func (s *CharmSuite) TestPreferredSeries(c *gc.C) {
+	tests := []struct {
+		supportedSeries []string
+		preferredSeries []string
+		expectSeries    string
+		err             string
+	}{{
+		err: "series not specified and charm does not define any",
+	}, {
+		preferredSeries: []string{"xenial", "trusty"},
+		expectSeries:    "xenial",
+	}, {
+		supportedSeries: []string{"precise", "trusty", "xenial"},
+		preferredSeries: []string{"xenial", "trusty"},
+		expectSeries:    "xenial",
+	}, {
+		supportedSeries: []string{"precise", "trusty"},
+		preferredSeries: []string{"xenial", "trusty", "precise"},
+		expectSeries:    "trusty",
+	}, {
+		supportedSeries: []string{"win2012r2", "trusty"},
+		preferredSeries: []string{"win2012r2"},
+		expectSeries:    "win2012r2",
+	}, {
+		supportedSeries: []string{"precise", "trusty"},
+		preferredSeries: []string{"xenial", "wily"},
+		err:             `none of the preferred series \[xenial wily\] supported by charm, supported series are: precise,trusty`,
+	}, {
+		supportedSeries: []string{"precise", "trusty"},
+		err:             `none of the preferred series \[\] supported by charm, supported series are: precise,trusty`,
+	}}
+	for i, test := range tests {
+		c.Logf("test %d: supported %v, preferred %v", i, test.supportedSeries, test.preferredSeries)
+		series, err := charm.PreferredSeries(test.supportedSeries, test.preferredSeries)
+		if test.err != "" {
+			c.Assert(err, gc.ErrorMatches, test.err)
+			c.Assert(series, gc.Equals, "")
+			continue
+		}
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(series, gc.Equals, test.expectSeries)
+	}
+}
+
+func (s *CharmSuite) TestPreferredSeriesUnsupportedError(c *gc.C) {
+	_, err := charm.PreferredSeries([]string{"precise"}, []string{"xenial"})
+	c.Assert(charm.IsUnsupportedSeriesError(err), jc.IsTrue)
+}
+
 func (s *CharmSuite) IsUnsupportedSeriesError(c *gc.C) {
 	err := charm.NewUnsupportedSeriesError("series", []string{"supported"})
 	c.Assert(charm.IsUnsupportedSeriesError(err), jc.IsTrue)
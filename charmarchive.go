@@ -11,8 +11,11 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/juju/utils/set"
 	ziputil "github.com/juju/utils/zip"
@@ -28,6 +31,7 @@ type CharmArchive struct {
 	config   *Config
 	metrics  *Metrics
 	actions  *Actions
+	manifest *CharmManifest
 	revision int
 }
 
@@ -117,6 +121,17 @@ func readCharmArchive(zopen zipOpener) (archive *CharmArchive, err error) {
 		}
 	}
 
+	reader, err = zipOpenFile(zipr, "manifest.yaml")
+	if err == nil {
+		b.manifest, err = ReadCharmManifest(reader)
+		reader.Close()
+		if err != nil {
+			return nil, fmt.Errorf("cannot read manifest.yaml: %v", err)
+		}
+	} else if _, ok := err.(*noCharmArchiveFile); !ok {
+		return nil, err
+	}
+
 	reader, err = zipOpenFile(zipr, "revision")
 	if err != nil {
 		if _, ok := err.(*noCharmArchiveFile); !ok {
@@ -185,6 +200,13 @@ func (a *CharmArchive) Actions() *Actions {
 	return a.actions
 }
 
+// CharmManifest returns the CharmManifest representing the
+// manifest.yaml file for the charm archive, or nil if the
+// charm has no manifest.
+func (a *CharmArchive) CharmManifest() *CharmManifest {
+	return a.manifest
+}
+
 type zipReadCloser struct {
 	io.Closer
 	*zip.Reader
@@ -228,6 +250,7 @@ func (zo *zipPathOpener) openZip() (*zipReadCloser, error) {
 	}
 	r, err := zip.NewReader(f, fi.Size())
 	if err != nil {
+		err = archiveFormatError(zo.path, f, err)
 		f.Close()
 		return nil, err
 	}
@@ -242,11 +265,39 @@ type zipReaderOpener struct {
 func (zo *zipReaderOpener) openZip() (*zipReadCloser, error) {
 	r, err := zip.NewReader(zo.r, zo.size)
 	if err != nil {
-		return nil, err
+		return nil, archiveFormatError("", zo.r, err)
 	}
 	return &zipReadCloser{Closer: ioutil.NopCloser(nil), Reader: r}, nil
 }
 
+// archivePrefixSize holds the number of bytes included in the
+// error returned when a file is not a valid archive.
+const archivePrefixSize = 64
+
+// archiveFormatError returns a descriptive error for data in r that
+// could not be read as a zip archive. The error includes the path of
+// the archive, if known, and the first bytes of the data, so that, for
+// instance, an HTML or JSON error body served in place of an archive
+// can be recognized.
+func archiveFormatError(path string, r io.ReaderAt, err error) error {
+	if err != zip.ErrFormat {
+		if path != "" {
+			return fmt.Errorf("cannot read archive %q: %v", path, err)
+		}
+		return err
+	}
+	what := "invalid archive"
+	if path != "" {
+		what = fmt.Sprintf("invalid archive %q", path)
+	}
+	prefix := make([]byte, archivePrefixSize)
+	n, _ := r.ReadAt(prefix, 0)
+	if n == 0 {
+		return fmt.Errorf("%s: %v (no data)", what, err)
+	}
+	return fmt.Errorf("%s: %v (data starts with %q)", what, err, prefix[:n])
+}
+
 // Manifest returns a set of the charm's contents.
 func (a *CharmArchive) Manifest() (set.Strings, error) {
 	zipr, err := a.zopen.openZip()
@@ -266,6 +317,80 @@ func (a *CharmArchive) Manifest() (set.Strings, error) {
 	return manifest, nil
 }
 
+// ReadFile returns the contents of the regular file at the given
+// slash-separated path inside the charm archive, for instance
+// "config.yaml" or "hooks/install". The path must be relative
+// to the root of the archive and may not contain ".." elements.
+// Directories and symbolic links cannot be read.
+func (a *CharmArchive) ReadFile(p string) ([]byte, error) {
+	if !isValidArchivePath(p) {
+		return nil, fmt.Errorf("invalid archive path %q", p)
+	}
+	zipr, err := a.zopen.openZip()
+	if err != nil {
+		return nil, err
+	}
+	defer zipr.Close()
+	for _, f := range zipr.File {
+		if f.Name != p {
+			continue
+		}
+		if !f.Mode().IsRegular() {
+			return nil, fmt.Errorf("archive file %q is not a regular file", p)
+		}
+		r, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return ioutil.ReadAll(r)
+	}
+	return nil, &noCharmArchiveFile{p}
+}
+
+// HookNames returns the sorted names of the hooks present in the charm
+// archive, that is the files in its hooks directory that are valid
+// hooks for the charm, as returned by Meta.Hooks. Other files in the
+// hooks directory are ignored. Charms without hooks, such as reactive
+// or operator charms, have no hook names.
+func (a *CharmArchive) HookNames() ([]string, error) {
+	zipr, err := a.zopen.openZip()
+	if err != nil {
+		return nil, err
+	}
+	defer zipr.Close()
+	validHooks := a.meta.Hooks()
+	names := []string{}
+	for _, f := range zipr.File {
+		if path.Dir(f.Name) != "hooks" || f.Mode().IsDir() {
+			continue
+		}
+		if name := path.Base(f.Name); validHooks[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// IsOperatorCharm reports whether the charm archive holds a charm
+// written using the operator framework, which handles all events
+// through a dispatch script at the root of the archive, rather than
+// a classic charm relying on individual hooks.
+func (a *CharmArchive) IsOperatorCharm() (bool, error) {
+	zipr, err := a.zopen.openZip()
+	if err != nil {
+		return false, err
+	}
+	defer zipr.Close()
+	for _, f := range zipr.File {
+		if f.Name == "dispatch" {
+			return f.Mode().IsRegular(), nil
+		}
+	}
+	return false, nil
+}
+
 // ExpandTo expands the charm archive into dir, creating it if necessary.
 // If any errors occur during the expansion procedure, the process will
 // abort.
@@ -301,6 +426,162 @@ func (a *CharmArchive) ExpandTo(dir string) error {
 	return nil
 }
 
+// RepackTo writes a new charm archive to w, holding the contents
+// of the charm archive modified by the given changes, and with its
+// revision set to revision. The changes map holds file contents
+// indexed by archive path: existing files are replaced, new files
+// are added and files with nil contents are removed. The metadata.yaml
+// file cannot be removed, and directories and symbolic links cannot be
+// replaced.
+//
+// The headers of unchanged files, including their modes and
+// modification times, are preserved, and hooks are made
+// owner-executable. The archive is repacked without expanding it
+// to disk.
+func (a *CharmArchive) RepackTo(w io.Writer, changes map[string][]byte, revision int) error {
+	for path, data := range changes {
+		if err := checkRepackPath(path); err != nil {
+			return err
+		}
+		if path == "metadata.yaml" && data == nil {
+			return fmt.Errorf("cannot remove metadata.yaml")
+		}
+	}
+	hooks := a.meta.Hooks()
+	if data := changes["metadata.yaml"]; data != nil {
+		// The new metadata may define a different set of hooks.
+		meta, err := ReadMeta(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("cannot read new metadata.yaml: %v", err)
+		}
+		hooks = meta.Hooks()
+	}
+	zipr, err := a.zopen.openZip()
+	if err != nil {
+		return err
+	}
+	defer zipr.Close()
+	for _, f := range zipr.File {
+		name := strings.TrimSuffix(f.Name, "/")
+		if _, changed := changes[name]; !changed {
+			continue
+		}
+		switch mode := f.Mode(); {
+		case mode.IsDir():
+			return fmt.Errorf("cannot change directory %q", name)
+		case mode&os.ModeSymlink != 0 && changes[name] != nil:
+			return fmt.Errorf("cannot replace symlink %q", name)
+		}
+	}
+
+	zipw := zip.NewWriter(w)
+	zp := zipPacker{Writer: zipw, hooks: hooks}
+	if err := zp.AddRevision(revision); err != nil {
+		return err
+	}
+	existing := make(map[string]bool)
+	for _, f := range zipr.File {
+		if f.Name == "revision" {
+			continue
+		}
+		existing[f.Name] = true
+		data, changed := changes[f.Name]
+		if !changed {
+			if err := zp.copyFile(f); err != nil {
+				return fmt.Errorf("cannot repack %q: %v", f.Name, err)
+			}
+			continue
+		}
+		if data == nil {
+			continue
+		}
+		if err := zp.addFile(f.Name, f.Mode(), data); err != nil {
+			return fmt.Errorf("cannot repack %q: %v", f.Name, err)
+		}
+	}
+	// Add the new files in a predictable order.
+	var paths []string
+	for path, data := range changes {
+		if data != nil && !existing[path] {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if err := zp.addFile(path, 0644, changes[path]); err != nil {
+			return fmt.Errorf("cannot repack %q: %v", path, err)
+		}
+	}
+	return zipw.Close()
+}
+
+// checkRepackPath checks that the given path can be used
+// to change a file when repacking a charm archive.
+func checkRepackPath(p string) error {
+	switch {
+	case !isValidArchivePath(p):
+		return fmt.Errorf("invalid archive path %q", p)
+	case p == "revision":
+		return fmt.Errorf("cannot change the revision file: use the revision argument instead")
+	}
+	return nil
+}
+
+// isValidArchivePath reports whether p is a clean relative
+// slash-separated path that does not lead out of the archive.
+func isValidArchivePath(p string) bool {
+	return p != "" && p == path.Clean(p) && !path.IsAbs(p) && p != ".." && !strings.HasPrefix(p, "../")
+}
+
+// copyFile copies the given archive file with its header,
+// fixing its permissions if it is a hook.
+func (zp *zipPacker) copyFile(f *zip.File) error {
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	h := f.FileHeader
+	if f.Mode().IsDir() {
+		h.Method = zip.Store
+	}
+	h.SetMode(zp.hookMode(f.Name, f.Mode()))
+	w, err := zp.CreateHeader(&h)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// addFile adds a regular file with the given contents to the archive.
+// Only the permission bits of mode are used.
+func (zp *zipPacker) addFile(name string, mode os.FileMode, data []byte) error {
+	h := &zip.FileHeader{
+		Name:   name,
+		Method: zip.Deflate,
+	}
+	h.SetMode(zp.hookMode(name, mode&0777))
+	w, err := zp.CreateHeader(h)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// hookMode returns the given mode, made owner-executable
+// if the named file is a hook.
+func (zp *zipPacker) hookMode(name string, mode os.FileMode) os.FileMode {
+	if mode&os.ModeType != 0 || path.Dir(name) != "hooks" {
+		return mode
+	}
+	if zp.hooks[path.Base(name)] {
+		mode |= 0100
+	}
+	return mode
+}
+
 // fixHookFunc returns a WalkFunc that makes sure hooks are owner-executable.
 func fixHookFunc(hooksDir string, hookNames map[string]bool) filepath.WalkFunc {
 	return func(path string, info os.FileInfo, err error) error {
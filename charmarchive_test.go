@@ -11,8 +11,10 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"syscall"
+	"time"
 
 	jc "github.com/juju/testing/checkers"
 	"github.com/juju/utils/set"
@@ -20,6 +22,7 @@ import (
 	"gopkg.in/yaml.v2"
 
 	"gopkg.in/juju/charm.v6-unstable"
+	"gopkg.in/juju/charm.v6-unstable/hooks"
 )
 
 type CharmArchiveSuite struct {
@@ -121,6 +124,32 @@ func (s *CharmArchiveSuite) TestReadCharmArchiveFromReader(c *gc.C) {
 	checkDummy(c, archive, "")
 }
 
+func (s *CharmArchiveSuite) TestReadCharmArchiveNotZip(c *gc.C) {
+	body := `<html><head><title>502 Bad Gateway</title></head><body>bad gateway</body></html>`
+	archive, err := charm.ReadCharmArchiveBytes([]byte(body))
+	c.Assert(err, gc.ErrorMatches, `invalid archive: zip: not a valid zip file \(data starts with "<html><head><title>502 Bad Gateway</title></head><body>bad gatew"\)`)
+	c.Assert(archive, gc.IsNil)
+
+	path := filepath.Join(c.MkDir(), "bogus.charm")
+	err = ioutil.WriteFile(path, []byte(`{"Message":"not found"}`), 0644)
+	c.Assert(err, gc.IsNil)
+	archive, err = charm.ReadCharmArchive(path)
+	c.Assert(err, gc.ErrorMatches, `invalid archive "`+regexp.QuoteMeta(path)+`": zip: not a valid zip file \(data starts with "{\\"Message\\":\\"not found\\"}"\)`)
+	c.Assert(archive, gc.IsNil)
+
+	archive, err = charm.ReadCharmArchiveBytes(nil)
+	c.Assert(err, gc.ErrorMatches, `invalid archive: zip: not a valid zip file \(no data\)`)
+	c.Assert(archive, gc.IsNil)
+}
+
+func (s *CharmArchiveSuite) TestReadCharmArchiveTruncated(c *gc.C) {
+	data, err := ioutil.ReadFile(s.archivePath)
+	c.Assert(err, gc.IsNil)
+	archive, err := charm.ReadCharmArchiveBytes(data[:len(data)/2])
+	c.Assert(err, gc.ErrorMatches, `invalid archive: zip: not a valid zip file \(data starts with "PK.*"\)`)
+	c.Assert(archive, gc.IsNil)
+}
+
 func (s *CharmArchiveSuite) TestManifest(c *gc.C) {
 	archive, err := charm.ReadCharmArchive(s.archivePath)
 	c.Assert(err, gc.IsNil)
@@ -157,6 +186,131 @@ func (s *CharmArchiveSuite) TestManifestSymlink(c *gc.C) {
 	c.Assert(manifest, gc.DeepEquals, set.NewStrings(expected...))
 }
 
+func (s *CharmArchiveSuite) TestReadFile(c *gc.C) {
+	archive, err := charm.ReadCharmArchive(s.archivePath)
+	c.Assert(err, gc.IsNil)
+	for _, name := range []string{"config.yaml", "hooks/install", "src/hello.c"} {
+		expect, err := ioutil.ReadFile(filepath.Join(charmDirPath(c, "dummy"), name))
+		c.Assert(err, gc.IsNil)
+		data, err := archive.ReadFile(name)
+		c.Assert(err, gc.IsNil)
+		c.Assert(string(data), gc.Equals, string(expect))
+	}
+}
+
+var readFileErrorTests = []struct {
+	path string
+	err  string
+}{{
+	path: "no-such-file",
+	err:  `archive file "no-such-file" not found`,
+}, {
+	path: "",
+	err:  `invalid archive path ""`,
+}, {
+	path: "../dummy/config.yaml",
+	err:  `invalid archive path "../dummy/config.yaml"`,
+}, {
+	path: "/config.yaml",
+	err:  `invalid archive path "/config.yaml"`,
+}, {
+	path: "hooks/../../config.yaml",
+	err:  `invalid archive path "hooks/../../config.yaml"`,
+}, {
+	path: "./config.yaml",
+	err:  `invalid archive path "./config.yaml"`,
+}, {
+	path: "hooks/symlink",
+	err:  `archive file "hooks/symlink" is not a regular file`,
+}}
+
+func (s *CharmArchiveSuite) TestReadFileErrors(c *gc.C) {
+	srcPath := cloneDir(c, charmDirPath(c, "dummy"))
+	if err := os.Symlink("install", filepath.Join(srcPath, "hooks/symlink")); err != nil {
+		c.Skip("cannot symlink")
+	}
+	archive := archiveDir(c, srcPath)
+	for i, test := range readFileErrorTests {
+		c.Logf("test %d: %q", i, test.path)
+		data, err := archive.ReadFile(test.path)
+		c.Assert(err, gc.ErrorMatches, test.err)
+		c.Assert(data, gc.IsNil)
+	}
+}
+
+func (s *CharmArchiveSuite) TestHookNames(c *gc.C) {
+	archive := archiveDir(c, charmDirPath(c, "all-hooks"))
+	names, err := archive.HookNames()
+	c.Assert(err, gc.IsNil)
+	// The "otherdata" file and the "subdir" directory are not hooks.
+	c.Assert(names, jc.DeepEquals, []string{
+		"bar-relation-broken",
+		"bar-relation-changed",
+		"bar-relation-departed",
+		"bar-relation-joined",
+		"collect-metrics",
+		"config-changed",
+		"foo-relation-broken",
+		"foo-relation-changed",
+		"foo-relation-departed",
+		"foo-relation-joined",
+		"install",
+		"meter-status-changed",
+		"self-relation-broken",
+		"self-relation-changed",
+		"self-relation-departed",
+		"self-relation-joined",
+		"start",
+		"stop",
+		"upgrade-charm",
+	})
+	allKinds := hooks.RelationHooks()
+	c.Assert(archive.Meta().RelationHookKinds(names), jc.DeepEquals, map[string][]hooks.Kind{
+		"foo":  allKinds,
+		"bar":  allKinds,
+		"self": allKinds,
+	})
+}
+
+func (s *CharmArchiveSuite) TestIsOperatorCharm(c *gc.C) {
+	archive := archiveDir(c, charmDirPath(c, "all-hooks"))
+	isOperator, err := archive.IsOperatorCharm()
+	c.Assert(err, gc.IsNil)
+	c.Assert(isOperator, gc.Equals, false)
+
+	archive = archiveDir(c, charmDirPath(c, "dispatch"))
+	isOperator, err = archive.IsOperatorCharm()
+	c.Assert(err, gc.IsNil)
+	c.Assert(isOperator, gc.Equals, true)
+	names, err := archive.HookNames()
+	c.Assert(err, gc.IsNil)
+	c.Assert(names, gc.HasLen, 0)
+}
+
+func (s *CharmArchiveSuite) TestHookNamesPartial(c *gc.C) {
+	path := cloneDir(c, charmDirPath(c, "all-hooks"))
+	for _, name := range []string{"foo-relation-joined", "foo-relation-broken", "bar-relation-changed"} {
+		err := os.Remove(filepath.Join(path, "hooks", name))
+		c.Assert(err, gc.IsNil)
+	}
+	archive := archiveDir(c, path)
+	names, err := archive.HookNames()
+	c.Assert(err, gc.IsNil)
+	c.Assert(archive.Meta().RelationHookKinds(names), jc.DeepEquals, map[string][]hooks.Kind{
+		"foo":  {hooks.RelationChanged, hooks.RelationDeparted},
+		"bar":  {hooks.RelationJoined, hooks.RelationDeparted, hooks.RelationBroken},
+		"self": hooks.RelationHooks(),
+	})
+}
+
+func (s *CharmArchiveSuite) TestHookNamesWithoutHooks(c *gc.C) {
+	archive := archiveDir(c, charmDirPath(c, "terms"))
+	names, err := archive.HookNames()
+	c.Assert(err, gc.IsNil)
+	c.Assert(names, gc.HasLen, 0)
+	c.Assert(names, gc.NotNil)
+}
+
 func (s *CharmArchiveSuite) TestExpandTo(c *gc.C) {
 	archive, err := charm.ReadCharmArchive(s.archivePath)
 	c.Assert(err, gc.IsNil)
@@ -329,6 +483,196 @@ func (s *CharmArchiveSuite) TestCharmArchiveSetRevision(c *gc.C) {
 	c.Assert(dir.Revision(), gc.Equals, 42)
 }
 
+func (s *CharmArchiveSuite) TestRepackTo(c *gc.C) {
+	archive, err := charm.ReadCharmArchive(s.archivePath)
+	c.Assert(err, gc.IsNil)
+
+	changes := map[string][]byte{
+		"config.yaml": []byte("options:\n  title: {type: string, default: repacked}\n"),
+		"src/hello.c": nil,
+		"hooks/start": []byte("#!/bin/sh\n"),
+		"README.md":   []byte("A repacked charm.\n"),
+	}
+	var buf bytes.Buffer
+	err = archive.RepackTo(&buf, changes, 42)
+	c.Assert(err, gc.IsNil)
+	c.Assert(changes, gc.HasLen, 4)
+
+	repacked, err := charm.ReadCharmArchiveBytes(buf.Bytes())
+	c.Assert(err, gc.IsNil)
+	c.Assert(repacked.Revision(), gc.Equals, 42)
+	c.Assert(repacked.Meta(), jc.DeepEquals, archive.Meta())
+	c.Assert(repacked.Config().Options, jc.DeepEquals, map[string]charm.Option{
+		"title": {Type: "string", Default: "repacked"},
+	})
+	manifest, err := repacked.Manifest()
+	c.Assert(err, gc.IsNil)
+	expected := set.NewStrings(dummyManifest...)
+	expected.Remove("src/hello.c")
+	expected.Add("hooks/start")
+	expected.Add("README.md")
+	c.Assert(manifest, jc.DeepEquals, expected)
+
+	path := filepath.Join(c.MkDir(), "charm")
+	err = repacked.ExpandTo(path)
+	c.Assert(err, gc.IsNil)
+	for name, perm := range map[string]os.FileMode{
+		"hooks/install": 0755,
+		"hooks/start":   0744,
+		"README.md":     0644,
+	} {
+		info, err := os.Stat(filepath.Join(path, name))
+		c.Assert(err, gc.IsNil)
+		c.Assert(info.Mode()&0777, gc.Equals, perm, gc.Commentf("%s", name))
+	}
+	data, err := ioutil.ReadFile(filepath.Join(path, "hooks/start"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(string(data), gc.Equals, "#!/bin/sh\n")
+	dir, err := charm.ReadCharmDir(path)
+	c.Assert(err, gc.IsNil)
+	c.Assert(dir.Revision(), gc.Equals, 42)
+}
+
+func (s *CharmArchiveSuite) TestRepackToWithNewMetadata(c *gc.C) {
+	archive, err := charm.ReadCharmArchive(s.archivePath)
+	c.Assert(err, gc.IsNil)
+
+	var buf bytes.Buffer
+	err = archive.RepackTo(&buf, map[string][]byte{
+		"metadata.yaml":    []byte("name: renamed\nsummary: s\ndescription: d\n"),
+		"hooks/db-changed": []byte("#!/bin/sh\n"),
+	}, 1)
+	c.Assert(err, gc.IsNil)
+
+	repacked, err := charm.ReadCharmArchiveBytes(buf.Bytes())
+	c.Assert(err, gc.IsNil)
+	c.Assert(repacked.Meta().Name, gc.Equals, "renamed")
+	path := filepath.Join(c.MkDir(), "charm")
+	err = repacked.ExpandTo(path)
+	c.Assert(err, gc.IsNil)
+	info, err := os.Stat(filepath.Join(path, "hooks/db-changed"))
+	c.Assert(err, gc.IsNil)
+	// The new metadata does not define a db relation.
+	c.Assert(info.Mode()&0777, gc.Equals, os.FileMode(0644))
+}
+
+func (s *CharmArchiveSuite) TestRepackToPreservesHeaders(c *gc.C) {
+	modified := time.Date(2016, 3, 1, 12, 30, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	zipw := zip.NewWriter(&buf)
+	for _, f := range []struct {
+		name string
+		data string
+	}{
+		{"metadata.yaml", "name: headers\nsummary: s\ndescription: d\n"},
+		{"README.md", "Original.\n"},
+		{"config.yaml", "options: {}\n"},
+	} {
+		h := &zip.FileHeader{
+			Name:     f.name,
+			Method:   zip.Deflate,
+			Modified: modified,
+			Comment:  "comment for " + f.name,
+		}
+		h.SetMode(0640)
+		w, err := zipw.CreateHeader(h)
+		c.Assert(err, gc.IsNil)
+		_, err = w.Write([]byte(f.data))
+		c.Assert(err, gc.IsNil)
+	}
+	err := zipw.Close()
+	c.Assert(err, gc.IsNil)
+	archive, err := charm.ReadCharmArchiveBytes(buf.Bytes())
+	c.Assert(err, gc.IsNil)
+
+	var repacked bytes.Buffer
+	err = archive.RepackTo(&repacked, map[string][]byte{
+		"config.yaml": []byte("options:\n  title: {type: string}\n"),
+	}, 1)
+	c.Assert(err, gc.IsNil)
+	zipr, err := zip.NewReader(bytes.NewReader(repacked.Bytes()), int64(repacked.Len()))
+	c.Assert(err, gc.IsNil)
+	headers := make(map[string]zip.FileHeader)
+	for _, f := range zipr.File {
+		headers[f.Name] = f.FileHeader
+	}
+	for _, name := range []string{"metadata.yaml", "README.md"} {
+		h := headers[name]
+		c.Assert(h.Modified.Equal(modified), jc.IsTrue, gc.Commentf("%s: %v", name, h.Modified))
+		c.Assert(h.Comment, gc.Equals, "comment for "+name)
+		c.Assert(h.Mode()&0777, gc.Equals, os.FileMode(0640))
+	}
+	// The header of a replaced file is not preserved.
+	c.Assert(headers["config.yaml"].Comment, gc.Equals, "")
+}
+
+func (s *CharmArchiveSuite) TestRepackToSymlink(c *gc.C) {
+	charmDir := cloneDir(c, charmDirPath(c, "dummy"))
+	err := os.Symlink("install", filepath.Join(charmDir, "hooks", "start"))
+	c.Assert(err, gc.IsNil)
+	archive := archiveDir(c, charmDir)
+
+	var buf bytes.Buffer
+	err = archive.RepackTo(&buf, map[string][]byte{
+		"hooks/start": []byte("#!/bin/sh\n"),
+	}, 1)
+	c.Assert(err, gc.ErrorMatches, `cannot replace symlink "hooks/start"`)
+
+	// The symlink can be removed.
+	err = archive.RepackTo(&buf, map[string][]byte{
+		"hooks/start": nil,
+	}, 1)
+	c.Assert(err, gc.IsNil)
+	repacked, err := charm.ReadCharmArchiveBytes(buf.Bytes())
+	c.Assert(err, gc.IsNil)
+	manifest, err := repacked.Manifest()
+	c.Assert(err, gc.IsNil)
+	c.Assert(manifest, jc.DeepEquals, set.NewStrings(dummyManifest...))
+}
+
+var repackToErrorTests = []struct {
+	changes map[string][]byte
+	err     string
+}{{
+	changes: map[string][]byte{"": nil},
+	err:     `invalid archive path ""`,
+}, {
+	changes: map[string][]byte{"/etc/passwd": []byte("x")},
+	err:     `invalid archive path "/etc/passwd"`,
+}, {
+	changes: map[string][]byte{"../outside": []byte("x")},
+	err:     `invalid archive path "../outside"`,
+}, {
+	changes: map[string][]byte{"hooks/../config.yaml": nil},
+	err:     `invalid archive path "hooks/../config.yaml"`,
+}, {
+	changes: map[string][]byte{"revision": []byte("3")},
+	err:     `cannot change the revision file: use the revision argument instead`,
+}, {
+	changes: map[string][]byte{"metadata.yaml": []byte("name: [bad")},
+	err:     `cannot read new metadata.yaml: .*`,
+}, {
+	changes: map[string][]byte{"metadata.yaml": nil},
+	err:     `cannot remove metadata.yaml`,
+}, {
+	changes: map[string][]byte{"hooks": []byte("x")},
+	err:     `cannot change directory "hooks"`,
+}, {
+	changes: map[string][]byte{"empty": nil},
+	err:     `cannot change directory "empty"`,
+}}
+
+func (s *CharmArchiveSuite) TestRepackToErrors(c *gc.C) {
+	archive, err := charm.ReadCharmArchive(s.archivePath)
+	c.Assert(err, gc.IsNil)
+	for i, test := range repackToErrorTests {
+		c.Logf("test %d: %v", i, test.err)
+		var buf bytes.Buffer
+		err := archive.RepackTo(&buf, test.changes, 1)
+		c.Assert(err, gc.ErrorMatches, test.err)
+	}
+}
+
 func (s *CharmArchiveSuite) TestExpandToWithBadLink(c *gc.C) {
 	charmDir := cloneDir(c, charmDirPath(c, "dummy"))
 	badLink := filepath.Join(charmDir, "hooks", "badlink")
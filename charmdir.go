@@ -23,6 +23,7 @@ type CharmDir struct {
 	config   *Config
 	metrics  *Metrics
 	actions  *Actions
+	manifest *CharmManifest
 	revision int
 }
 
@@ -87,6 +88,17 @@ func ReadCharmDir(path string) (dir *CharmDir, err error) {
 		}
 	}
 
+	file, err = os.Open(dir.join("manifest.yaml"))
+	if err == nil {
+		dir.manifest, err = ReadCharmManifest(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("cannot read manifest.yaml: %v", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
 	if file, err = os.Open(dir.join("revision")); err == nil {
 		_, err = fmt.Fscan(file, &dir.revision)
 		file.Close()
@@ -135,6 +147,13 @@ func (dir *CharmDir) Actions() *Actions {
 	return dir.actions
 }
 
+// CharmManifest returns the CharmManifest representing the
+// manifest.yaml file for the charm expanded in dir, or nil
+// if the charm has no manifest.
+func (dir *CharmDir) CharmManifest() *CharmManifest {
+	return dir.manifest
+}
+
 // SetRevision changes the charm revision number. This affects
 // the revision reported by Revision and the revision of the
 // charm archived by ArchiveTo.
@@ -229,6 +229,30 @@ func (m Meta) Hooks() map[string]bool {
 	return allHooks
 }
 
+// RelationHookKinds returns, for each relation defined in m, the
+// kinds of relation hook found in the given hook names, in the order
+// returned by hooks.RelationHooks. Relations with no hooks map to an
+// empty slice.
+func (m Meta) RelationHookKinds(hookNames []string) map[string][]hooks.Kind {
+	present := make(map[string]bool)
+	for _, name := range hookNames {
+		present[name] = true
+	}
+	kinds := make(map[string][]hooks.Kind)
+	for _, relations := range []map[string]Relation{m.Provides, m.Requires, m.Peers} {
+		for relName := range relations {
+			relKinds := []hooks.Kind{}
+			for _, kind := range hooks.RelationHooks() {
+				if present[fmt.Sprintf("%s-%s", relName, kind)] {
+					relKinds = append(relKinds, kind)
+				}
+			}
+			kinds[relName] = relKinds
+		}
+	}
+	return kinds
+}
+
 // Used for parsing Categories and Tags.
 func parseStringList(list interface{}) []string {
 	if list == nil {
@@ -494,7 +518,7 @@ func (m Meta) MarshalYAML() (interface{}, error) {
 }
 
 type marshaledResourceMeta struct {
-	Path        string `yaml:"filename"` // TODO(ericsnow) Change to "path"?
+	Path        string `yaml:"filename,omitempty"` // TODO(ericsnow) Change to "path"?
 	Type        string `yaml:"type,omitempty"`
 	Description string `yaml:"description,omitempty"`
 }
@@ -893,6 +893,9 @@ resources:
     bar:
         filename: 'y.tgz'
         type: file
+    baz:
+        type: oci-image
+        description: 'an image'
 `,
 }}
 
@@ -1245,6 +1248,59 @@ resources:
 	})
 }
 
+func (s *MetaSuite) TestResourcesContainerImage(c *gc.C) {
+	meta, err := charm.ReadMeta(strings.NewReader(`
+name: a
+summary: b
+description: c
+resources:
+    image:
+        type: oci-image
+        description: "The workload image."
+`))
+	c.Assert(err, gc.IsNil)
+
+	c.Check(meta.Resources, jc.DeepEquals, map[string]resource.Meta{
+		"image": resource.Meta{
+			Name:        "image",
+			Type:        resource.TypeContainerImage,
+			Description: "The workload image.",
+		},
+	})
+}
+
+func (s *MetaSuite) TestResourcesFileMissingFilename(c *gc.C) {
+	_, err := charm.ReadMeta(strings.NewReader(`
+name: a
+summary: b
+description: c
+resources:
+    resource-name:
+        type: file
+`))
+	c.Assert(err, gc.ErrorMatches, `metadata: resources.resource-name: resource missing filename`)
+}
+
+func (s *MetaSuite) TestResourcesFromCharmDir(c *gc.C) {
+	ch := readCharmDir(c, "resources")
+	c.Check(ch.Meta().Resources, jc.DeepEquals, map[string]resource.Meta{
+		"config-tarball": resource.Meta{
+			Name:        "config-tarball",
+			Type:        resource.TypeFile,
+			Path:        "config.tgz",
+			Description: "The configuration used by the workload.",
+		},
+		"workload-image": resource.Meta{
+			Name:        "workload-image",
+			Type:        resource.TypeContainerImage,
+			Description: "The image running the workload.",
+		},
+	})
+
+	ch = readCharmDir(c, "dummy")
+	c.Check(ch.Meta().Resources, gc.HasLen, 0)
+}
+
 func (s *MetaSuite) TestParseResourceMetaOkay(c *gc.C) {
 	name := "my-resource"
 	data := map[string]interface{}{
@@ -70,6 +70,17 @@ func (s *MetaSuite) TestValidateMissingPath(c *gc.C) {
 	c.Check(err, gc.ErrorMatches, `resource missing filename`)
 }
 
+func (s *MetaSuite) TestValidateContainerImageWithoutPath(c *gc.C) {
+	res := resource.Meta{
+		Name:        "my-image",
+		Type:        resource.TypeContainerImage,
+		Description: "The image used by the workload.",
+	}
+	err := res.Validate()
+
+	c.Check(err, jc.ErrorIsNil)
+}
+
 func (s *MetaSuite) TestValidateNestedPath(c *gc.C) {
 	res := resource.Meta{
 		Name: "my-resource",
@@ -11,10 +11,12 @@ import (
 const (
 	typeUnknown Type = iota
 	TypeFile
+	TypeContainerImage
 )
 
 var types = map[Type]string{
-	TypeFile: "file",
+	TypeFile:           "file",
+	TypeContainerImage: "oci-image",
 }
 
 // Type enumerates the recognized resource types.
@@ -5,6 +5,7 @@ package charm
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/juju/errors"
 	"github.com/juju/schema"
@@ -12,7 +13,28 @@ import (
 	"gopkg.in/juju/charm.v6-unstable/resource"
 )
 
-var resourceSchema = schema.FieldMap(
+var resourceSchema = resourceC{}
+
+// resourceC checks a resource with resourceFieldsSchema, then
+// checks that file resources specify a filename.
+type resourceC struct{}
+
+func (c resourceC) Coerce(v interface{}, path []string) (newv interface{}, err error) {
+	newv, err = resourceFieldsSchema.Coerce(v, path)
+	if err != nil {
+		return nil, err
+	}
+	m := newv.(map[string]interface{})
+	if _, ok := m["filename"]; !ok && m["type"] == resource.TypeFile.String() {
+		if len(path) == 0 {
+			return nil, fmt.Errorf("resource missing filename")
+		}
+		return nil, fmt.Errorf("%s: resource missing filename", strings.Join(path[1:], ""))
+	}
+	return newv, nil
+}
+
+var resourceFieldsSchema = schema.FieldMap(
 	schema.Fields{
 		"type":        schema.String(),
 		"filename":    schema.String(), // TODO(ericsnow) Change to "path"?
@@ -20,6 +42,7 @@ var resourceSchema = schema.FieldMap(
 	},
 	schema.Defaults{
 		"type":        resource.TypeFile.String(),
+		"filename":    schema.Omit, // Only required for file resources.
 		"description": "",
 	},
 )
@@ -68,7 +68,22 @@ func (s *resourceSuite) TestSchemaMissingPath(c *gc.C) {
 	}
 	_, err := charm.ResourceSchema.Coerce(raw, nil)
 
-	c.Check(err, gc.NotNil)
+	c.Check(err, gc.ErrorMatches, "resource missing filename")
+}
+
+func (s *resourceSuite) TestSchemaContainerImageWithoutPath(c *gc.C) {
+	// The filename is only required for file resources.
+	raw := map[interface{}]interface{}{
+		"type":        "oci-image",
+		"description": "One line that is useful when operators need to push it.",
+	}
+	v, err := charm.ResourceSchema.Coerce(raw, nil)
+	c.Assert(err, jc.ErrorIsNil)
+
+	c.Check(v, jc.DeepEquals, map[string]interface{}{
+		"type":        "oci-image",
+		"description": "One line that is useful when operators need to push it.",
+	})
 }
 
 func (s *resourceSuite) TestSchemaMissingComment(c *gc.C) {
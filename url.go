@@ -92,6 +92,29 @@ func (url *URL) WithRevision(revision int) *URL {
 	return &urlCopy
 }
 
+// SameCharm reports whether a and b refer to the same charm or
+// bundle, ignoring their revisions. Whether a promulgated URL and
+// a URL owned by a user refer to the same entity can only be
+// determined by the charm store, so such URLs are reported as
+// different, as are URLs with and without a series.
+func SameCharm(a, b *URL) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Schema == b.Schema &&
+		a.User == b.User &&
+		a.Name == b.Name &&
+		a.Series == b.Series
+}
+
+// SameRevision reports whether a and b refer to the same revision
+// of the same charm or bundle, as described in SameCharm. URLs
+// without a revision do not identify a specific revision, so they
+// are never reported as the same revision.
+func SameRevision(a, b *URL) bool {
+	return SameCharm(a, b) && a != nil && a.Revision >= 0 && a.Revision == b.Revision
+}
+
 // MustParseURL works like ParseURL, but panics in case of errors.
 func MustParseURL(url string) *URL {
 	u, err := ParseURL(url)
@@ -167,7 +190,7 @@ func parseV1URL(url *gourl.URL, originalURL string) (*URL, error) {
 		}
 	}
 	i := 0
-	parts := strings.Split(url.Path[i:], "/")
+	parts := strings.Split(cleanURLPath(url.Path[i:]), "/")
 	if len(parts) < 1 || len(parts) > 4 {
 		return nil, errors.Errorf("charm or bundle URL has invalid form: %q", originalURL)
 	}
@@ -227,7 +250,7 @@ func parseV1URL(url *gourl.URL, originalURL string) (*URL, error) {
 func parseV2URL(url *gourl.URL) (*URL, error) {
 	var r URL
 	r.Schema = "cs"
-	parts := strings.Split(strings.Trim(url.Path, "/"), "/")
+	parts := strings.Split(strings.TrimPrefix(cleanURLPath(url.Path), "/"), "/")
 	if parts[0] == "u" {
 		if len(parts) < 3 {
 			return nil, errors.Errorf(`charm or bundle URL %q malformed, expected "/u/<user>/<name>"`, url)
@@ -269,6 +292,21 @@ func parseV2URL(url *gourl.URL) (*URL, error) {
 	return &r, nil
 }
 
+// cleanURLPath returns the given charm or bundle URL path with
+// duplicate and trailing slashes removed, so that, for instance,
+// "trusty//wordpress/" is treated as "trusty/wordpress". A leading
+// slash is preserved.
+func cleanURLPath(p string) string {
+	parts := strings.FieldsFunc(p, func(r rune) bool {
+		return r == '/'
+	})
+	cleaned := strings.Join(parts, "/")
+	if strings.HasPrefix(p, "/") {
+		cleaned = "/" + cleaned
+	}
+	return cleaned
+}
+
 func (r *URL) path() string {
 	var parts []string
 	if r.User != "" {
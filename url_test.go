@@ -233,6 +233,29 @@ var urlTests = []struct {
 }, {
 	s:   "cs:foo/~blah",
 	err: `cannot parse URL $URL: name "~blah" not valid`,
+}, {
+	s:     "cs:trusty//wordpress/",
+	exact: "cs:trusty/wordpress",
+	url:   &charm.URL{"cs", "", "wordpress", -1, "trusty"},
+}, {
+	s:     "cs:~user//trusty///wordpress-42",
+	exact: "cs:~user/trusty/wordpress-42",
+	url:   &charm.URL{"cs", "user", "wordpress", 42, "trusty"},
+}, {
+	s:     "trusty/wordpress//",
+	exact: "cs:trusty/wordpress",
+	url:   &charm.URL{"cs", "", "wordpress", -1, "trusty"},
+}, {
+	s:     "local:wordpress/",
+	exact: "local:wordpress",
+	url:   &charm.URL{"local", "", "wordpress", -1, ""},
+}, {
+	s:     "https://jujucharms.com/u//user/name//series/1",
+	exact: "cs:~user/series/name-1",
+	url:   &charm.URL{"cs", "user", "name", 1, "series"},
+}, {
+	s:   "cs:~user//",
+	err: `URL without charm or bundle name: $URL`,
 }}
 
 func (s *URLSuite) TestParseURL(c *gc.C) {
@@ -395,6 +418,72 @@ func (s *URLSuite) TestWithRevision(c *gc.C) {
 	c.Assert(other.WithRevision(1), gc.DeepEquals, other)
 }
 
+var sameCharmTests = []struct {
+	a, b         string
+	sameCharm    bool
+	sameRevision bool
+}{{
+	a:            "cs:trusty/wordpress-42",
+	b:            "cs:trusty/wordpress-42",
+	sameCharm:    true,
+	sameRevision: true,
+}, {
+	a:         "cs:trusty/wordpress-42",
+	b:         "cs:trusty/wordpress-43",
+	sameCharm: true,
+}, {
+	a:         "cs:trusty/wordpress",
+	b:         "cs:trusty/wordpress-42",
+	sameCharm: true,
+}, {
+	a:         "cs:trusty/wordpress",
+	b:         "cs:trusty/wordpress",
+	sameCharm: true,
+}, {
+	a:            "cs:~who/trusty/wordpress-1",
+	b:            "cs:~who/trusty/wordpress-1",
+	sameCharm:    true,
+	sameRevision: true,
+}, {
+	a: "cs:~who/trusty/wordpress-1",
+	b: "cs:trusty/wordpress-1",
+}, {
+	a: "cs:~who/trusty/wordpress-1",
+	b: "cs:~other/trusty/wordpress-1",
+}, {
+	a: "cs:trusty/wordpress-1",
+	b: "cs:xenial/wordpress-1",
+}, {
+	a: "cs:wordpress-1",
+	b: "cs:trusty/wordpress-1",
+}, {
+	a: "cs:trusty/wordpress-1",
+	b: "local:trusty/wordpress-1",
+}, {
+	a: "cs:trusty/wordpress-1",
+	b: "cs:trusty/mysql-1",
+}}
+
+func (s *URLSuite) TestSameCharm(c *gc.C) {
+	for i, test := range sameCharmTests {
+		c.Logf("test %d: %s %s", i, test.a, test.b)
+		a, b := charm.MustParseURL(test.a), charm.MustParseURL(test.b)
+		c.Assert(charm.SameCharm(a, b), gc.Equals, test.sameCharm)
+		c.Assert(charm.SameCharm(b, a), gc.Equals, test.sameCharm)
+		c.Assert(charm.SameRevision(a, b), gc.Equals, test.sameRevision)
+		c.Assert(charm.SameRevision(b, a), gc.Equals, test.sameRevision)
+	}
+}
+
+func (s *URLSuite) TestSameCharmNil(c *gc.C) {
+	url := charm.MustParseURL("cs:trusty/wordpress-1")
+	c.Assert(charm.SameCharm(nil, nil), gc.Equals, true)
+	c.Assert(charm.SameCharm(url, nil), gc.Equals, false)
+	c.Assert(charm.SameCharm(nil, url), gc.Equals, false)
+	c.Assert(charm.SameRevision(nil, nil), gc.Equals, false)
+	c.Assert(charm.SameRevision(url, nil), gc.Equals, false)
+}
+
 var codecs = []struct {
 	Name      string
 	Marshal   func(interface{}) ([]byte, error)